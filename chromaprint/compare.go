@@ -0,0 +1,143 @@
+package chromaprint
+
+import (
+	"math"
+
+	"github.com/acoustid/go-acoustid/util"
+)
+
+const compareMatchBits = 14
+const compareMatchMask = (1 << compareMatchBits) - 1
+
+func compareMatchStrip(x uint32) int {
+	return int(x >> (32 - compareMatchBits))
+}
+
+// CompareFingerprints computes the similarity of two fingerprints, in the range from 0 to 1.
+// It's a port of the acoustid_compare2 function from the AcoustID PostgreSQL extension and
+// it returns exactly the same scores, including its quirks. The fingerprints are aligned using
+// 14-bit prefixes of the hashes, if maxOffset is not 0, only alignments up to that many items are considered.
+func CompareFingerprints(a []uint32, b []uint32, maxOffset int) float32 {
+	asize := len(a)
+	bsize := len(b)
+
+	// The C code keeps both offset tables in one buffer of 16-bit integers, which is later reused
+	// as a byte array for counting unique hashes. The last byte is never cleared, so the buffer
+	// is emulated here to get the same results.
+	offsets := make([]uint16, 2*(compareMatchMask+1))
+	aoffsets := offsets[:compareMatchMask+1]
+	boffsets := offsets[compareMatchMask+1:]
+
+	for i, x := range a {
+		aoffsets[compareMatchStrip(x)] = uint16(i)
+	}
+	for i, x := range b {
+		boffsets[compareMatchStrip(x)] = uint16(i)
+	}
+
+	counts := make([]uint16, asize+bsize+1)
+	topCount := 0
+	topOffset := 0
+	for i := 0; i < compareMatchMask; i++ {
+		if aoffsets[i] != 0 && boffsets[i] != 0 {
+			offset := int(aoffsets[i]) - int(boffsets[i])
+			if maxOffset == 0 || (-maxOffset <= offset && offset <= maxOffset) {
+				offset += bsize
+				counts[offset]++
+				if int(counts[offset]) > topCount {
+					topCount = int(counts[offset])
+					topOffset = offset
+				}
+			}
+		}
+	}
+
+	topOffset -= bsize
+
+	minSize := minInt(asize, bsize) &^ 1
+	if topOffset < 0 {
+		b = b[minInt(-topOffset, bsize):]
+	} else {
+		a = a[minInt(topOffset, asize):]
+	}
+	asize = len(a)
+	bsize = len(b)
+
+	size := minInt(asize, bsize) / 2
+	if size == 0 || minSize == 0 {
+		return 0
+	}
+
+	seen := compareSeenBuffer{offsets: offsets}
+	auniq := seen.countUnique(a)
+	buniq := seen.countUnique(b)
+
+	diversity := float32(math.Min(
+		math.Min(1.0, float64(float32(auniq+10)/float32(asize))+0.5),
+		math.Min(1.0, float64(float32(buniq+10)/float32(bsize))+0.5)))
+
+	if float64(topCount) < float64(maxInt(auniq, buniq))*0.02 {
+		return 0
+	}
+
+	bitError := 0
+	for i := 0; i < size*2; i++ {
+		bitError += util.PopCount32(a[i] ^ b[i])
+	}
+
+	score := float32((float64(size) * 2.0 / float64(minSize)) * (1.0 - 2.0*float64(float32(bitError))/float64(64*size)))
+	if score < 0 {
+		score = 0
+	}
+	if diversity < 1.0 {
+		score = float32(math.Pow(float64(score), 8.0-7.0*float64(diversity)))
+	}
+	return score
+}
+
+// compareSeenBuffer views the offset tables as a byte array, the way acoustid_compare2 does.
+type compareSeenBuffer struct {
+	offsets []uint16
+}
+
+func (s compareSeenBuffer) get(i int) bool {
+	return s.offsets[i/2]>>(8*uint(i%2))&0xff != 0
+}
+
+func (s compareSeenBuffer) set(i int) {
+	shift := 8 * uint(i%2)
+	s.offsets[i/2] = s.offsets[i/2]&^(0xff<<shift) | 1<<shift
+}
+
+// countUnique counts distinct 14-bit hash prefixes, only clearing the first compareMatchMask bytes before that.
+func (s compareSeenBuffer) countUnique(hashes []uint32) int {
+	for i := 0; i < compareMatchMask/2; i++ {
+		s.offsets[i] = 0
+	}
+	if compareMatchMask%2 == 1 {
+		s.offsets[compareMatchMask/2] &= 0xff00
+	}
+	count := 0
+	for _, x := range hashes {
+		key := compareMatchStrip(x)
+		if !s.get(key) {
+			count++
+			s.set(key)
+		}
+	}
+	return count
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,65 @@
+package chromaprint
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareFingerprints_Same(t *testing.T) {
+	fp := loadTestFingerprint(t, "calibre_sunrise")
+	assert.Equal(t, float32(1.0), CompareFingerprints(fp.Hashes, fp.Hashes, 0))
+}
+
+func TestCompareFingerprints_NoMatch(t *testing.T) {
+	fp1 := loadTestFingerprint(t, "calibre_sunrise")
+	fp2 := loadTestFingerprint(t, "radio1_1_ad")
+	assert.Equal(t, float32(0.0), CompareFingerprints(fp1.Hashes, fp2.Hashes, 0))
+}
+
+func TestCompareFingerprints_PartialMatch(t *testing.T) {
+	fp1 := loadTestFingerprint(t, "calibre_sunrise")
+	fp2 := loadTestFingerprint(t, "radio1_3_calibre_sunshine")
+	score := CompareFingerprints(fp1.Hashes, fp2.Hashes, 0)
+	assert.InDelta(t, 0.366, score, 0.001)
+	assert.Equal(t, score, CompareFingerprints(fp2.Hashes, fp1.Hashes, 0))
+}
+
+func TestCompareFingerprints_Offset(t *testing.T) {
+	fp := loadTestFingerprint(t, "calibre_sunrise")
+	shifted := fp.Hashes[10:]
+	assert.Equal(t, float32(1.0), CompareFingerprints(fp.Hashes, shifted, 0))
+	assert.Equal(t, float32(1.0), CompareFingerprints(shifted, fp.Hashes, 0))
+	assert.Equal(t, float32(1.0), CompareFingerprints(fp.Hashes, shifted, 10))
+	assert.Equal(t, float32(0.0), CompareFingerprints(fp.Hashes, shifted, 5))
+}
+
+func TestCompareFingerprints_LowDiversity(t *testing.T) {
+	a := make([]uint32, 100)
+	b := make([]uint32, 100)
+	for i := range a {
+		a[i] = 0xabcd1234
+		b[i] = 0xabcd1235
+	}
+	// 1 bit error in each item, and only one unique hash
+	score := 1.0 - 2.0*100.0/(64.0*50.0)
+	diversity := float32(math.Min(1.0, float64(float32(11)/float32(100))+0.5))
+	expected := float32(math.Pow(score, 8.0-7.0*float64(diversity)))
+	assert.Equal(t, expected, CompareFingerprints(a, b, 0))
+}
+
+func TestCompareFingerprints_Empty(t *testing.T) {
+	fp := loadTestFingerprint(t, "calibre_sunrise")
+	assert.Equal(t, float32(0.0), CompareFingerprints(fp.Hashes, nil, 0))
+	assert.Equal(t, float32(0.0), CompareFingerprints(nil, nil, 0))
+}
+
+func BenchmarkCompareFingerprints(b *testing.B) {
+	fp1 := loadTestFingerprint(b, "calibre_sunrise")
+	fp2 := loadTestFingerprint(b, "radio1_3_calibre_sunshine")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CompareFingerprints(fp1.Hashes, fp2.Hashes, 0)
+	}
+}
@@ -13,7 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func loadTestFingerprint(t *testing.T, name string) *Fingerprint {
+func loadTestFingerprint(t testing.TB, name string) *Fingerprint {
 	data, err := ioutil.ReadFile(path.Join("..", "testdata", name+".txt"))
 	require.NoError(t, err)
 	fp, err := ParseFingerprintString(string(data))
@@ -0,0 +1,26 @@
+package app_db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type Account struct {
+	ID     int
+	Name   string
+	APIKey string
+}
+
+// GetAccountByAPIKey returns the account with the given user API key, or nil if there is no such account.
+func (s *AppDB) GetAccountByAPIKey(ctx context.Context, apiKey string) (*Account, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT id, name, apikey FROM account WHERE apikey = $1", apiKey)
+	var account Account
+	err := row.Scan(&account.ID, &account.Name, &account.APIKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &account, nil
+}
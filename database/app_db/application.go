@@ -0,0 +1,34 @@
+package app_db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type Application struct {
+	ID        int
+	Name      string
+	Version   string
+	APIKey    string
+	AccountID int
+	Active    bool
+}
+
+// GetApplicationByAPIKey returns the application with the given API key, or nil if there is no such application.
+func (s *AppDB) GetApplicationByAPIKey(ctx context.Context, apiKey string) (*Application, error) {
+	query := `
+SELECT id, name, version, apikey, account_id, coalesce(active, false)
+FROM application
+WHERE apikey = $1
+`
+	row := s.db.QueryRowContext(ctx, query, apiKey)
+	var app Application
+	err := row.Scan(&app.ID, &app.Name, &app.Version, &app.APIKey, &app.AccountID, &app.Active)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &app, nil
+}
@@ -0,0 +1,17 @@
+package app_db
+
+import (
+	"database/sql"
+)
+
+type AppDB struct {
+	db *sql.DB
+}
+
+func NewAppDB(db *sql.DB) *AppDB {
+	return &AppDB{db: db}
+}
+
+func (s *AppDB) Close() error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package app_db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// FindOrInsertSource returns the ID of the source for the given application/account pair, creating it if needed.
+func (s *AppDB) FindOrInsertSource(ctx context.Context, applicationID int, accountID int, version string) (int, error) {
+	var versionValue sql.NullString
+	if version != "" {
+		versionValue = sql.NullString{String: version, Valid: true}
+	}
+	selectQuery := `
+SELECT id FROM source
+WHERE application_id = $1 AND account_id = $2 AND version IS NOT DISTINCT FROM $3
+`
+	insertQuery := `
+INSERT INTO source (application_id, account_id, version) VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+RETURNING id
+`
+	return findOrInsert(ctx, s.db, selectQuery, insertQuery, applicationID, accountID, versionValue)
+}
+
+// FindOrInsertFormat returns the ID of the file format with the given name, creating it if needed.
+func (s *AppDB) FindOrInsertFormat(ctx context.Context, name string) (int, error) {
+	selectQuery := "SELECT id FROM format WHERE name = $1"
+	insertQuery := "INSERT INTO format (name) VALUES ($1) ON CONFLICT DO NOTHING RETURNING id"
+	return findOrInsert(ctx, s.db, selectQuery, insertQuery, name)
+}
+
+func findOrInsert(ctx context.Context, db *sql.DB, selectQuery string, insertQuery string, args ...interface{}) (int, error) {
+	for i := 0; i < 2; i++ {
+		var id int
+		err := db.QueryRowContext(ctx, selectQuery, args...).Scan(&id)
+		if err == nil {
+			return id, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
+		err = db.QueryRowContext(ctx, insertQuery, args...).Scan(&id)
+		if err == nil {
+			return id, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
+		// somebody else inserted the same row concurrently, try to select it again
+	}
+	return 0, sql.ErrNoRows
+}
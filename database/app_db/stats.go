@@ -0,0 +1,44 @@
+package app_db
+
+import (
+	"context"
+	"time"
+)
+
+type LookupStats struct {
+	Date          time.Time
+	Hour          int
+	ApplicationID int
+	Hits          int
+	NoHits        int
+}
+
+// UpdateLookupStats adds the counts to the hourly lookup statistics, inserting new rows if needed.
+// It requires the unique index on (date, hour, application_id), see sql/app/migrations/001_stats_lookups_idx_uniq.sql.
+func (s *AppDB) UpdateLookupStats(ctx context.Context, stats []LookupStats) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+INSERT INTO stats_lookups (date, hour, application_id, count_hits, count_nohits)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (date, hour, application_id) DO UPDATE
+SET count_hits = stats_lookups.count_hits + EXCLUDED.count_hits, count_nohits = stats_lookups.count_nohits + EXCLUDED.count_nohits
+`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, s := range stats {
+		_, err = stmt.ExecContext(ctx, s.Date.Format("2006-01-02"), s.Hour, s.ApplicationID, s.Hits, s.NoHits)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
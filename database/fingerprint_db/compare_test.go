@@ -0,0 +1,66 @@
+package fingerprint_db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/acoustid/go-acoustid/chromaprint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestFingerprint(t *testing.T, name string) chromaprint.Fingerprint {
+	data, err := ioutil.ReadFile(path.Join("..", "..", "testdata", name+".txt"))
+	require.NoError(t, err)
+	fp, err := chromaprint.ParseFingerprintString(string(data))
+	require.NoError(t, err)
+	return fp
+}
+
+// TestCompareFingerprintsParity checks that the Go implementation of acoustid_compare2
+// returns the same scores as the PostgreSQL extension.
+func TestCompareFingerprintsParity(t *testing.T) {
+	db, err := sql.Open("fingerprint_db_tx", t.Name())
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Ping()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	names := []string{
+		"calibre_sunrise",
+		"radio1_1_ad",
+		"radio1_2_ad_and_calibre_sunshine",
+		"radio1_3_calibre_sunshine",
+		"radio1_4_calibre_sunshine",
+		"radio1_5_calibre_sunshine",
+	}
+	fingerprints := make(map[string][]uint32)
+	for _, name := range names {
+		fingerprints[name] = loadTestFingerprint(t, name).Hashes
+	}
+	fingerprints["calibre_sunrise_shifted"] = fingerprints["calibre_sunrise"][37:]
+	fingerprints["calibre_sunrise_short"] = fingerprints["calibre_sunrise"][:101]
+	names = append(names, "calibre_sunrise_shifted", "calibre_sunrise_short")
+
+	for _, name1 := range names {
+		for _, name2 := range names {
+			for _, maxOffset := range []int{0, 20} {
+				t.Run(fmt.Sprintf("%s/%s/%d", name1, name2, maxOffset), func(t *testing.T) {
+					a := fingerprints[name1]
+					b := fingerprints[name2]
+					var expected float32
+					err := db.QueryRowContext(ctx, "SELECT acoustid_compare2($1, $2, $3)", Uint32Array(a), Uint32Array(b), maxOffset).Scan(&expected)
+					require.NoError(t, err)
+					assert.Equal(t, expected, chromaprint.CompareFingerprints(a, b, maxOffset))
+				})
+			}
+		}
+	}
+}
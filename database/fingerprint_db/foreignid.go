@@ -0,0 +1,103 @@
+package fingerprint_db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// FindOrInsertForeignID returns the ID of the foreign ID, creating it and its vendor if needed.
+func (s *FingerprintDB) FindOrInsertForeignID(ctx context.Context, vendor string, name string) (int, error) {
+	vendorID, err := s.findOrInsert(ctx,
+		"SELECT id FROM foreignid_vendor WHERE name = $1",
+		"INSERT INTO foreignid_vendor (name) VALUES ($1) ON CONFLICT DO NOTHING RETURNING id",
+		vendor)
+	if err != nil {
+		return 0, err
+	}
+	return s.findOrInsert(ctx,
+		"SELECT id FROM foreignid WHERE vendor_id = $1 AND name = $2",
+		"INSERT INTO foreignid (vendor_id, name) VALUES ($1, $2) ON CONFLICT DO NOTHING RETURNING id",
+		vendorID, name)
+}
+
+func (s *FingerprintDB) findOrInsert(ctx context.Context, selectQuery string, insertQuery string, args ...interface{}) (int, error) {
+	for i := 0; i < 2; i++ {
+		var id int
+		err := s.db.QueryRowContext(ctx, selectQuery, args...).Scan(&id)
+		if err == nil {
+			return id, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
+		err = s.db.QueryRowContext(ctx, insertQuery, args...).Scan(&id)
+		if err == nil {
+			return id, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
+		// somebody else inserted the same row concurrently, try to select it again
+	}
+	return 0, sql.ErrNoRows
+}
+
+type ForeignID struct {
+	Vendor string
+	Name   string
+}
+
+// GetTrackForeignIDs returns foreign IDs linked to the given tracks, keyed by track ID.
+// Foreign IDs are ordered by the number of submissions.
+func (s *FingerprintDB) GetTrackForeignIDs(ctx context.Context, trackIDs []int) (map[int][]ForeignID, error) {
+	query := `
+SELECT tf.track_id, v.name, f.name
+FROM track_foreignid tf
+JOIN foreignid f ON tf.foreignid_id = f.id
+JOIN foreignid_vendor v ON f.vendor_id = v.id
+WHERE tf.track_id = any($1)
+ORDER BY tf.track_id, tf.submission_count DESC, f.id
+`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(trackIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	foreignIDs := make(map[int][]ForeignID)
+	for rows.Next() {
+		var trackID int
+		var foreignID ForeignID
+		err = rows.Scan(&trackID, &foreignID.Vendor, &foreignID.Name)
+		if err != nil {
+			return nil, err
+		}
+		foreignIDs[trackID] = append(foreignIDs[trackID], foreignID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return foreignIDs, nil
+}
+
+// GetTracksByForeignIDs returns tracks linked to the given foreign IDs, keyed by "vendor:name".
+func (s *FingerprintDB) GetTracksByForeignIDs(ctx context.Context, foreignIDs []ForeignID) (map[string][]LinkedTrack, error) {
+	vendors := make([]string, len(foreignIDs))
+	names := make([]string, len(foreignIDs))
+	for i, foreignID := range foreignIDs {
+		vendors[i] = foreignID.Vendor
+		names[i] = foreignID.Name
+	}
+	query := `
+SELECT v.name || ':' || f.name, t.gid, false
+FROM track_foreignid tf
+JOIN foreignid f ON tf.foreignid_id = f.id
+JOIN foreignid_vendor v ON f.vendor_id = v.id
+JOIN track t ON tf.track_id = t.id
+WHERE (v.name, f.name) IN (SELECT * FROM unnest($1::text[], $2::text[]))
+ORDER BY v.name, f.name, tf.submission_count DESC, t.id
+`
+	return s.getLinkedTracks(ctx, query, pq.Array(vendors), pq.Array(names))
+}
@@ -0,0 +1,42 @@
+package fingerprint_db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Meta contains user-submitted metadata of an audio file.
+type Meta struct {
+	Track       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	TrackNo     int
+	DiscNo      int
+	Year        int
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt(i int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(i), Valid: i != 0}
+}
+
+// InsertMeta stores the metadata and returns its ID.
+func (s *FingerprintDB) InsertMeta(ctx context.Context, meta *Meta) (int, error) {
+	query := `
+INSERT INTO meta (track, artist, album, album_artist, track_no, disc_no, year)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id
+`
+	var id int
+	err := s.db.QueryRowContext(ctx, query,
+		nullString(meta.Track), nullString(meta.Artist), nullString(meta.Album), nullString(meta.AlbumArtist),
+		nullInt(meta.TrackNo), nullInt(meta.DiscNo), nullInt(meta.Year)).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
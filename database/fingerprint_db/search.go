@@ -2,39 +2,56 @@ package fingerprint_db
 
 import (
 	"context"
-	"log"
+	"database/sql"
 	"math"
+	"strconv"
 	"time"
 
 	"github.com/lib/pq"
 )
 
 type ScoredSearchMatch struct {
-	FingerprintID int
-	TrackID       int
-	TrackGID      string
-	Score         float64
+	FingerprintID   int
+	TrackID         int
+	TrackGID        string
+	TrackNewID      int
+	Score           float64
+	SubmissionCount int
 }
 
-func (s *FingerprintDB) ScoreSearchMatches(ctx context.Context, hashes []uint32, candidateIDs []int, duration time.Duration) ([]ScoredSearchMatch, error) {
+// durationRange returns the range of fingerprint lengths, in seconds, matching the duration.
+func durationRange(duration time.Duration, maxDurationDiff time.Duration) (int, int) {
+	minDurationSecs := int(math.Floor((duration - maxDurationDiff).Seconds()))
+	maxDurationSecs := int(math.Ceil((duration + maxDurationDiff).Seconds()))
+	return minDurationSecs, maxDurationSecs
+}
+
+// ScoreSearchMatches compares the fingerprint with the candidate fingerprints whose duration differs
+// by at most maxDurationDiff from the given duration. Matches are ordered by score.
+func (s *FingerprintDB) ScoreSearchMatches(ctx context.Context, hashes []uint32, candidateIDs []int, duration time.Duration, maxDurationDiff time.Duration) ([]ScoredSearchMatch, error) {
 	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
 	defer txn.Rollback()
 
 	if deadline, ok := ctx.Deadline(); ok {
-		statementTimeout := time.Now().Sub(deadline) * 99 / 100
+		statementTimeout := time.Until(deadline) * 99 / 100
 		if statementTimeout > 0 {
-			txn.ExecContext(ctx, `SET LOCAL statement_timeout = ?`, statementTimeout)
+			// SET doesn't accept query parameters, set_config with is_local=true is the same as SET LOCAL.
+			_, err = txn.ExecContext(ctx, `SELECT set_config('statement_timeout', $1, true)`, strconv.FormatInt(statementTimeout.Milliseconds(), 10))
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	minDurationSecs := int(math.Round(duration.Seconds() - 7))
-	maxDurationSecs := minDurationSecs + 14
-	log.Printf("%v %v", minDurationSecs, maxDurationSecs)
+	minDurationSecs, maxDurationSecs := durationRange(duration, maxDurationDiff)
 
 	query := `
-SELECT fingerprint_id, track_id, track.gid AS track_gid, score
+SELECT fingerprint_id, track_id, track.gid AS track_gid, track.new_id AS track_new_id, score, submission_count
 FROM (
-	SELECT id AS fingerprint_id, track_id, acoustid_compare2(fingerprint, $1) AS score
+	SELECT id AS fingerprint_id, track_id, acoustid_compare2(fingerprint, $1) AS score, submission_count
 	FROM fingerprint
 	WHERE
 		id = any($2)
@@ -47,14 +64,95 @@ ORDER BY score DESC
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	matches := []ScoredSearchMatch{}
 	for rows.Next() {
 		var match ScoredSearchMatch
-		err = rows.Scan(&match.FingerprintID, &match.TrackID, &match.TrackGID, &match.Score)
+		var trackNewID sql.NullInt64
+		err = rows.Scan(&match.FingerprintID, &match.TrackID, &match.TrackGID, &trackNewID, &match.Score, &match.SubmissionCount)
 		if err != nil {
 			return nil, err
 		}
+		match.TrackNewID = int(trackNewID.Int64)
 		matches = append(matches, match)
 	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
 	return matches, nil
 }
+
+// SearchCandidate is a fingerprint that can be compared with the searched fingerprint.
+type SearchCandidate struct {
+	FingerprintID   int
+	Length          int
+	TrackID         int
+	TrackGID        string
+	TrackNewID      int
+	SubmissionCount int
+}
+
+// MatchesDuration returns true if the fingerprint length differs by at most maxDurationDiff from the given duration.
+func (c SearchCandidate) MatchesDuration(duration time.Duration, maxDurationDiff time.Duration) bool {
+	minDurationSecs, maxDurationSecs := durationRange(duration, maxDurationDiff)
+	return c.Length >= minDurationSecs && c.Length <= maxDurationSecs
+}
+
+// GetSearchCandidates returns the candidate fingerprints with their lengths and tracks, without their hashes.
+// It's used for comparing fingerprints outside of the database.
+func (s *FingerprintDB) GetSearchCandidates(ctx context.Context, candidateIDs []int) ([]SearchCandidate, error) {
+	query := `
+SELECT f.id, f.length, f.track_id, t.gid, t.new_id, f.submission_count
+FROM fingerprint f
+JOIN track t ON f.track_id = t.id
+WHERE f.id = any($1)
+ORDER BY f.id
+`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(candidateIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var candidates []SearchCandidate
+	for rows.Next() {
+		var candidate SearchCandidate
+		var trackNewID sql.NullInt64
+		err = rows.Scan(&candidate.FingerprintID, &candidate.Length, &candidate.TrackID, &candidate.TrackGID, &trackNewID, &candidate.SubmissionCount)
+		if err != nil {
+			return nil, err
+		}
+		candidate.TrackNewID = int(trackNewID.Int64)
+		candidates = append(candidates, candidate)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// GetFingerprintHashes returns hashes of the given fingerprints, keyed by fingerprint ID.
+func (s *FingerprintDB) GetFingerprintHashes(ctx context.Context, ids []int) (map[int][]uint32, error) {
+	query := `SELECT id, fingerprint FROM fingerprint WHERE id = any($1)`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hashes := make(map[int][]uint32)
+	for rows.Next() {
+		var id int
+		var fingerprint Uint32Array
+		err = rows.Scan(&id, &fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		hashes[id] = fingerprint
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
@@ -25,7 +25,7 @@ func TestScoreSearchMatches(t *testing.T) {
 	ctx := context.Background()
 
 	fpDB := NewFingerprintDB(db)
-	matches, err := fpDB.ScoreSearchMatches(ctx, []uint32{1, 2, 3}, []int{1}, time.Minute)
+	matches, err := fpDB.ScoreSearchMatches(ctx, []uint32{1, 2, 3}, []int{1}, time.Minute, 7*time.Second)
 	require.NoError(t, err)
 
 	assert.Equal(t, matches, []ScoredSearchMatch{})
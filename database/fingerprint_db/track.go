@@ -0,0 +1,176 @@
+package fingerprint_db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// GetTrackMBIDs returns MusicBrainz recording IDs linked to the given tracks, keyed by track ID.
+// Disabled links are skipped. MBIDs are ordered by the number of submissions.
+func (s *FingerprintDB) GetTrackMBIDs(ctx context.Context, trackIDs []int) (map[int][]string, error) {
+	query := `
+SELECT track_id, mbid
+FROM track_mbid
+WHERE track_id = any($1) AND NOT disabled
+ORDER BY track_id, submission_count DESC, mbid
+`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(trackIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	mbids := make(map[int][]string)
+	for rows.Next() {
+		var trackID int
+		var mbid string
+		err = rows.Scan(&trackID, &mbid)
+		if err != nil {
+			return nil, err
+		}
+		mbids[trackID] = append(mbids[trackID], mbid)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return mbids, nil
+}
+
+type FingerprintTrack struct {
+	FingerprintID int
+	TrackID       int
+	TrackGID      string
+}
+
+// GetFingerprintTracks returns tracks of the given fingerprints, keyed by fingerprint ID.
+func (s *FingerprintDB) GetFingerprintTracks(ctx context.Context, fingerprintIDs []int) (map[int]FingerprintTrack, error) {
+	query := `
+SELECT f.id, t.id, t.gid
+FROM fingerprint f
+JOIN track t ON f.track_id = t.id
+WHERE f.id = any($1)
+`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(fingerprintIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tracks := make(map[int]FingerprintTrack)
+	for rows.Next() {
+		var track FingerprintTrack
+		err = rows.Scan(&track.FingerprintID, &track.TrackID, &track.TrackGID)
+		if err != nil {
+			return nil, err
+		}
+		tracks[track.FingerprintID] = track
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+type Track struct {
+	ID    int
+	GID   string
+	NewID int
+}
+
+// GetTrackByGID returns the track with the given AcoustID, or nil if it doesn't exist.
+// Merges are not followed, the caller can use NewID for that.
+func (s *FingerprintDB) GetTrackByGID(ctx context.Context, gid string) (*Track, error) {
+	query := `SELECT id, gid, new_id FROM track WHERE gid = $1`
+	var track Track
+	var newID sql.NullInt64
+	err := s.db.QueryRowContext(ctx, query, gid).Scan(&track.ID, &track.GID, &newID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	track.NewID = int(newID.Int64)
+	return &track, nil
+}
+
+// GetTracks returns tracks with the given IDs, keyed by track ID.
+func (s *FingerprintDB) GetTracks(ctx context.Context, ids []int) (map[int]Track, error) {
+	query := `SELECT id, gid, new_id FROM track WHERE id = any($1)`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tracks := make(map[int]Track)
+	for rows.Next() {
+		var track Track
+		var newID sql.NullInt64
+		err = rows.Scan(&track.ID, &track.GID, &newID)
+		if err != nil {
+			return nil, err
+		}
+		track.NewID = int(newID.Int64)
+		tracks[track.ID] = track
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+type LinkedTrack struct {
+	TrackGID string
+	Disabled bool
+}
+
+// GetTracksByMBIDs returns tracks linked to the given MusicBrainz recording IDs, keyed by MBID.
+// Disabled links are only returned if includeDisabled is true.
+func (s *FingerprintDB) GetTracksByMBIDs(ctx context.Context, mbids []string, includeDisabled bool) (map[string][]LinkedTrack, error) {
+	query := `
+SELECT tm.mbid, t.gid, tm.disabled
+FROM track_mbid tm
+JOIN track t ON tm.track_id = t.id
+WHERE tm.mbid = any($1::uuid[]) AND (NOT tm.disabled OR $2)
+ORDER BY tm.mbid, tm.submission_count DESC, t.id
+`
+	return s.getLinkedTracks(ctx, query, pq.Array(mbids), includeDisabled)
+}
+
+// GetTracksByPUIDs returns tracks linked to the given MusicDNS PUIDs, keyed by PUID.
+func (s *FingerprintDB) GetTracksByPUIDs(ctx context.Context, puids []string) (map[string][]LinkedTrack, error) {
+	query := `
+SELECT tp.puid, t.gid, false
+FROM track_puid tp
+JOIN track t ON tp.track_id = t.id
+WHERE tp.puid = any($1::uuid[])
+ORDER BY tp.puid, tp.submission_count DESC, t.id
+`
+	return s.getLinkedTracks(ctx, query, pq.Array(puids))
+}
+
+func (s *FingerprintDB) getLinkedTracks(ctx context.Context, query string, args ...interface{}) (map[string][]LinkedTrack, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tracks := make(map[string][]LinkedTrack)
+	for rows.Next() {
+		var key string
+		var track LinkedTrack
+		err = rows.Scan(&key, &track.TrackGID, &track.Disabled)
+		if err != nil {
+			return nil, err
+		}
+		tracks[key] = append(tracks[key], track)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
@@ -0,0 +1,42 @@
+package fingerprint_db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTracks(t *testing.T) {
+	db, err := sql.Open("fingerprint_db_tx", t.Name())
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, `
+INSERT INTO track (id, gid, new_id) VALUES
+	(101, 'a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e', NULL),
+	(102, '0f4e4b1c-3b5a-4c2d-8e1f-6a7b8c9d0e1f', 101)
+`)
+	require.NoError(t, err)
+
+	fpDB := NewFingerprintDB(db)
+
+	track, err := fpDB.GetTrackByGID(ctx, "0f4e4b1c-3b5a-4c2d-8e1f-6a7b8c9d0e1f")
+	require.NoError(t, err)
+	assert.Equal(t, &Track{ID: 102, GID: "0f4e4b1c-3b5a-4c2d-8e1f-6a7b8c9d0e1f", NewID: 101}, track)
+
+	track, err = fpDB.GetTrackByGID(ctx, "00000000-0000-4000-8000-000000000000")
+	require.NoError(t, err)
+	assert.Nil(t, track)
+
+	tracks, err := fpDB.GetTracks(ctx, []int{101, 102, 103})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]Track{
+		101: {ID: 101, GID: "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e"},
+		102: {ID: 102, GID: "0f4e4b1c-3b5a-4c2d-8e1f-6a7b8c9d0e1f", NewID: 101},
+	}, tracks)
+}
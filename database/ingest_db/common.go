@@ -0,0 +1,17 @@
+package ingest_db
+
+import (
+	"database/sql"
+)
+
+type IngestDB struct {
+	db *sql.DB
+}
+
+func NewIngestDB(db *sql.DB) *IngestDB {
+	return &IngestDB{db: db}
+}
+
+func (s *IngestDB) Close() error {
+	return nil
+}
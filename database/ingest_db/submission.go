@@ -0,0 +1,110 @@
+package ingest_db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+	"github.com/lib/pq"
+)
+
+type Submission struct {
+	Fingerprint []uint32
+	Length      int
+	Bitrate     int
+	FormatID    int
+	SourceID    int
+	MBID        string
+	PUID        string
+	MetaID      int
+	ForeignID   int
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt(i int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(i), Valid: i != 0}
+}
+
+// InsertSubmissions stores new unhandled submissions in a single transaction and returns their IDs.
+func (s *IngestDB) InsertSubmissions(ctx context.Context, submissions []Submission) ([]int, error) {
+	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+
+	query := `
+INSERT INTO submission (fingerprint, length, bitrate, format_id, source_id, mbid, puid, meta_id, foreignid_id, handled)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, false)
+RETURNING id
+`
+	ids := make([]int, len(submissions))
+	for i, submission := range submissions {
+		err = txn.QueryRowContext(ctx, query,
+			fingerprint_db.Uint32Array(submission.Fingerprint),
+			submission.Length,
+			nullInt(submission.Bitrate),
+			nullInt(submission.FormatID),
+			submission.SourceID,
+			nullString(submission.MBID),
+			nullString(submission.PUID),
+			nullInt(submission.MetaID),
+			nullInt(submission.ForeignID),
+		).Scan(&ids[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = txn.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+type SubmissionInfo struct {
+	ID            int
+	Handled       bool
+	FingerprintID int
+}
+
+// GetSubmissions returns the processing state of the given submissions.
+// FingerprintID is set if the submission was handled and resulted in a fingerprint.
+func (s *IngestDB) GetSubmissions(ctx context.Context, ids []int) ([]SubmissionInfo, error) {
+	query := `
+SELECT s.id, coalesce(s.handled, false), fs.fingerprint_id
+FROM submission s
+LEFT JOIN fingerprint_source fs ON fs.submission_id = s.id
+WHERE s.id = any($1)
+ORDER BY s.id
+`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var submissions []SubmissionInfo
+	for rows.Next() {
+		var submission SubmissionInfo
+		var fingerprintID sql.NullInt64
+		err = rows.Scan(&submission.ID, &submission.Handled, &fingerprintID)
+		if err != nil {
+			return nil, err
+		}
+		submission.FingerprintID = int(fingerprintID.Int64)
+		n := len(submissions)
+		if n > 0 && submissions[n-1].ID == submission.ID {
+			continue
+		}
+		submissions = append(submissions, submission)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
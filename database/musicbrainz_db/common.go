@@ -0,0 +1,17 @@
+package musicbrainz_db
+
+import (
+	"database/sql"
+)
+
+type MusicBrainzDB struct {
+	db *sql.DB
+}
+
+func NewMusicBrainzDB(db *sql.DB) *MusicBrainzDB {
+	return &MusicBrainzDB{db: db}
+}
+
+func (s *MusicBrainzDB) Close() error {
+	return nil
+}
@@ -0,0 +1,160 @@
+package musicbrainz_db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type ArtistCreditName struct {
+	ArtistGID  string
+	Name       string
+	JoinPhrase string
+}
+
+type Recording struct {
+	ID             int
+	GID            string
+	Name           string
+	Length         int
+	ArtistCreditID int
+}
+
+// RecordingTrack describes one appearance of a recording on a release.
+type RecordingTrack struct {
+	RecordingID                int
+	TrackGID                   string
+	TrackName                  string
+	TrackPosition              int
+	TrackArtistCreditID        int
+	MediumPosition             int
+	MediumFormat               string
+	MediumTrackCount           int
+	ReleaseGID                 string
+	ReleaseName                string
+	ReleaseArtistCreditID      int
+	ReleaseMediumCount         int
+	ReleaseGroupGID            string
+	ReleaseGroupName           string
+	ReleaseGroupType           string
+	ReleaseGroupArtistCreditID int
+}
+
+// GetRecordingsByGIDs returns recordings keyed by the requested MBIDs.
+// MBIDs of merged recordings are resolved using the redirect table.
+// Unknown MBIDs are not present in the result.
+func (s *MusicBrainzDB) GetRecordingsByGIDs(ctx context.Context, gids []string) (map[string]Recording, error) {
+	query := `
+SELECT r.gid AS requested_gid, r.id, r.gid, r.name, r.length, r.artist_credit
+FROM musicbrainz.recording r
+WHERE r.gid = any($1::uuid[])
+UNION ALL
+SELECT rd.gid AS requested_gid, r.id, r.gid, r.name, r.length, r.artist_credit
+FROM musicbrainz.recording_gid_redirect rd
+JOIN musicbrainz.recording r ON rd.new_id = r.id
+WHERE rd.gid = any($1::uuid[])
+`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(gids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	recordings := make(map[string]Recording)
+	for rows.Next() {
+		var requestedGID string
+		var recording Recording
+		var length sql.NullInt64
+		err = rows.Scan(&requestedGID, &recording.ID, &recording.GID, &recording.Name, &length, &recording.ArtistCreditID)
+		if err != nil {
+			return nil, err
+		}
+		recording.Length = int(length.Int64)
+		recordings[requestedGID] = recording
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return recordings, nil
+}
+
+// GetRecordingTracks returns all tracks of the given recordings, including information about their mediums,
+// releases and release groups.
+func (s *MusicBrainzDB) GetRecordingTracks(ctx context.Context, recordingIDs []int) ([]RecordingTrack, error) {
+	query := `
+SELECT
+	t.recording, t.gid, t.name, t.position, t.artist_credit,
+	m.position, mf.name, m.track_count,
+	rl.gid, rl.name, rl.artist_credit,
+	(SELECT count(*) FROM musicbrainz.medium WHERE release = rl.id),
+	rg.gid, rg.name, rgpt.name, rg.artist_credit
+FROM musicbrainz.track t
+JOIN musicbrainz.medium m ON t.medium = m.id
+LEFT JOIN musicbrainz.medium_format mf ON m.format = mf.id
+JOIN musicbrainz.release rl ON m.release = rl.id
+JOIN musicbrainz.release_group rg ON rl.release_group = rg.id
+LEFT JOIN musicbrainz.release_group_primary_type rgpt ON rg.type = rgpt.id
+WHERE t.recording = any($1)
+ORDER BY t.recording, rg.id, rl.id, m.position, t.position
+`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(recordingIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tracks []RecordingTrack
+	for rows.Next() {
+		var t RecordingTrack
+		var mediumFormat, releaseGroupType sql.NullString
+		err = rows.Scan(
+			&t.RecordingID, &t.TrackGID, &t.TrackName, &t.TrackPosition, &t.TrackArtistCreditID,
+			&t.MediumPosition, &mediumFormat, &t.MediumTrackCount,
+			&t.ReleaseGID, &t.ReleaseName, &t.ReleaseArtistCreditID,
+			&t.ReleaseMediumCount,
+			&t.ReleaseGroupGID, &t.ReleaseGroupName, &releaseGroupType, &t.ReleaseGroupArtistCreditID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		t.MediumFormat = mediumFormat.String
+		t.ReleaseGroupType = releaseGroupType.String
+		tracks = append(tracks, t)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// GetArtistCredits returns artist credits keyed by their IDs, with names in the order they should be displayed.
+func (s *MusicBrainzDB) GetArtistCredits(ctx context.Context, artistCreditIDs []int) (map[int][]ArtistCreditName, error) {
+	query := `
+SELECT acn.artist_credit, a.gid, acn.name, acn.join_phrase
+FROM musicbrainz.artist_credit_name acn
+JOIN musicbrainz.artist a ON acn.artist = a.id
+WHERE acn.artist_credit = any($1)
+ORDER BY acn.artist_credit, acn.position
+`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(artistCreditIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	credits := make(map[int][]ArtistCreditName)
+	for rows.Next() {
+		var id int
+		var name ArtistCreditName
+		err = rows.Scan(&id, &name.ArtistGID, &name.Name, &name.JoinPhrase)
+		if err != nil {
+			return nil, err
+		}
+		credits[id] = append(credits[id], name)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return credits, nil
+}
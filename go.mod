@@ -4,15 +4,17 @@ go 1.13
 
 require (
 	github.com/DATA-DOG/go-txdb v0.1.3
+	github.com/alicebob/miniredis/v2 v2.14.1
 	github.com/dchest/safefile v0.0.0-20151022103144-855e8d98f185
 	github.com/fortytw2/leaktest v1.3.0 // indirect
+	github.com/go-redis/redis/v8 v8.4.0
 	github.com/golang/protobuf v1.4.3
 	github.com/jolestar/go-commons-pool v2.0.0+incompatible
 	github.com/lib/pq v1.2.0
 	github.com/pkg/errors v0.9.1
 	github.com/prometheus/client_golang v1.8.0
 	github.com/sirupsen/logrus v1.6.0
-	github.com/stretchr/testify v1.4.0
+	github.com/stretchr/testify v1.6.1
 	github.com/ulule/loukoum/v3 v3.2.0
 	github.com/urfave/cli v1.22.1
 	go4.org v0.0.0-20190919214946-0cfe6e5be80f
@@ -103,6 +103,7 @@ func CreateApp() *cli.App {
 			Action: PrepareAndRunUpdater,
 		},
 		ProxyCommand,
+		ServeCommand,
 	}
 	return app
 }
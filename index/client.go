@@ -23,6 +23,9 @@ var ErrTxActive = errors.New("another transaction is still active")
 var ErrInvalidResultFormat = errors.New("invalid format of search results")
 
 func DecodeResults(encoded string) ([]*pb.Result, error) {
+	if encoded == "" {
+		return nil, nil
+	}
 	items := strings.Split(encoded, " ")
 	results := make([]*pb.Result, len(items))
 	for i, item := range items {
@@ -232,33 +235,19 @@ func (c *IndexClient) Search(ctx context.Context, in *pb.SearchRequest) (*pb.Sea
 }
 
 func (c *IndexClient) Insert(ctx context.Context, in *pb.InsertRequest) (*pb.InsertResponse, error) {
-	out := &pb.InsertResponse{}
-
-	fingerprints := in.GetFingerprints()
-	if len(fingerprints) == 0 {
-		return out, nil
-	}
-
-	tx, err := c.BeginTx(ctx)
+	err := InsertFingerprints(ctx, c, in.GetFingerprints())
 	if err != nil {
 		return nil, err
 	}
+	return &pb.InsertResponse{}, nil
+}
 
-	for _, fingerprint := range fingerprints {
-		err = tx.Insert(ctx, fingerprint.GetId(), fingerprint.GetHashes())
-		if err != nil {
-			tx.Rollback(ctx)
-			return nil, err
-		}
-	}
-
-	err = tx.Commit(ctx)
+func (c *IndexClient) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	err := DeleteFingerprints(ctx, c, in.GetIds())
 	if err != nil {
 		return nil, err
 	}
-
-	return out, nil
-
+	return &pb.DeleteResponse{}, nil
 }
 
 func (c *IndexClient) BeginTx(ctx context.Context) (Tx, error) {
@@ -290,6 +279,18 @@ func (tx *IndexClientTx) Insert(ctx context.Context, id uint32, hashes []uint32)
 	return err
 }
 
+func (tx *IndexClientTx) Delete(ctx context.Context, id uint32) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	_, err = tx.c.sendRequest(ctx, fmt.Sprintf("delete %d", id))
+	return err
+}
+
 func (tx *IndexClientTx) begin(ctx context.Context) error {
 	err := ctx.Err()
 	if err != nil {
@@ -75,38 +75,106 @@ func (p *IndexClientPool) Close(ctx context.Context) {
 	p.Pool.Close(ctx)
 }
 
-func (p *IndexClientPool) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+// withClient runs fn with a client borrowed from the pool. Clients that are in an error state
+// after fn returns are removed from the pool.
+func (p *IndexClientPool) withClient(ctx context.Context, fn func(idx *IndexClient) error) error {
 	obj, err := p.Pool.BorrowObject(ctx)
 	if err != nil {
 		log.Errorf("failed to borrow index client from the pool: %v", err)
-		return nil, err
+		return err
 	}
 
+	idx := obj.(*IndexClient)
 	defer func() {
+		if !idx.IsOK() {
+			err := p.Pool.InvalidateObject(context.Background(), obj)
+			if err != nil {
+				log.Errorf("failed to invalidate index client: %v", err)
+			}
+			return
+		}
 		err := p.Pool.ReturnObject(ctx, obj)
 		if err != nil {
 			log.Errorf("failed to return index client to the pool: %v", err)
 		}
 	}()
 
-	idx := obj.(*IndexClient)
-	return idx.Search(ctx, in)
+	return fn(idx)
+}
+
+func (p *IndexClientPool) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	var out *pb.SearchResponse
+	err := p.withClient(ctx, func(idx *IndexClient) error {
+		var err error
+		out, err = idx.Search(ctx, in)
+		return err
+	})
+	return out, err
 }
 
 func (p *IndexClientPool) Insert(ctx context.Context, in *pb.InsertRequest) (*pb.InsertResponse, error) {
-	obj, err := p.Pool.BorrowObject(ctx)
-	if err != nil {
-		log.Errorf("failed to borrow index client from the pool: %v", err)
-		return nil, err
-	}
+	var out *pb.InsertResponse
+	err := p.withClient(ctx, func(idx *IndexClient) error {
+		var err error
+		out, err = idx.Insert(ctx, in)
+		return err
+	})
+	return out, err
+}
 
-	defer func() {
-		err := p.Pool.ReturnObject(ctx, obj)
-		if err != nil {
-			log.Errorf("failed to return index client to the pool: %v", err)
-		}
-	}()
+func (p *IndexClientPool) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	var out *pb.DeleteResponse
+	err := p.withClient(ctx, func(idx *IndexClient) error {
+		var err error
+		out, err = idx.Delete(ctx, in)
+		return err
+	})
+	return out, err
+}
 
-	idx := obj.(*IndexClient)
-	return idx.Insert(ctx, in)
+func (p *IndexClientPool) Ping(ctx context.Context) error {
+	return p.withClient(ctx, func(idx *IndexClient) error {
+		return idx.Ping(ctx)
+	})
+}
+
+func (p *IndexClientPool) GetAttribute(ctx context.Context, name string) (string, error) {
+	var value string
+	err := p.withClient(ctx, func(idx *IndexClient) error {
+		var err error
+		value, err = idx.GetAttribute(ctx, name)
+		return err
+	})
+	return value, err
+}
+
+func (p *IndexClientPool) SetAttribute(ctx context.Context, name string, value string) error {
+	return p.withClient(ctx, func(idx *IndexClient) error {
+		return idx.SetAttribute(ctx, name, value)
+	})
+}
+
+// NumActive returns the number of connections currently used by requests.
+func (p *IndexClientPool) NumActive() int {
+	return p.Pool.GetNumActive()
+}
+
+// NumIdle returns the number of open connections waiting in the pool.
+func (p *IndexClientPool) NumIdle() int {
+	return p.Pool.GetNumIdle()
+}
+
+// SearcherPool is a pool of connections to one index server, used for searches.
+type SearcherPool interface {
+	Searcher
+	Close(ctx context.Context)
+}
+
+// NewSearcherPool creates a PipelinedIndexClientPool if config.PipelinedConnections is set,
+// otherwise an IndexClientPool with up to limit connections.
+func NewSearcherPool(config *IndexConfig, limit int) SearcherPool {
+	if config.PipelinedConnections > 0 {
+		return NewPipelinedIndexClientPool(config, config.PipelinedConnections)
+	}
+	return NewIndexClientPool(config, limit)
 }
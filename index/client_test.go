@@ -52,6 +52,7 @@ func TestIndexClient(t *testing.T) {
 		"set attribute foo baz":         "OK ",
 		"insert 1 100,200,300":          "OK ",
 		"insert 2 400,500,600":          "OK ",
+		"delete 1":                      "OK ",
 		"get attribute max_document_id": "OK 2",
 	}
 
@@ -82,6 +83,10 @@ func TestIndexClient(t *testing.T) {
 	assert.Nil(t, err, "got error from tx.Insert()")
 	assert.True(t, idx.IsOK())
 
+	err = tx.Delete(ctx, 1)
+	assert.Nil(t, err, "got error from tx.Delete()")
+	assert.True(t, idx.IsOK())
+
 	err = tx.Commit(ctx)
 	assert.Nil(t, err, "got error from tx.Commit()")
 	assert.True(t, idx.IsOK())
@@ -1,13 +1,123 @@
 package index
 
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PartitionByID means that each shard stores all hashes of fingerprints in its ID range.
+	PartitionByID = "id"
+	// PartitionByHash means that each shard stores a subset of hashes of all fingerprints.
+	PartitionByHash = "hash"
+)
+
+const (
+	// SelectRoundRobin sends requests to replicas in turn.
+	SelectRoundRobin = "round-robin"
+	// SelectLeastLoaded sends requests to the replica with the fewest requests in progress.
+	SelectLeastLoaded = "least-loaded"
+)
+
 type IndexConfig struct {
 	Host string
 	Port int
+	// Replicas lists addresses of additional index servers with the same data, in the host:port format.
+	Replicas []string
+	// ReplicaSelection is either SelectRoundRobin or SelectLeastLoaded.
+	ReplicaSelection string
+	// HedgePercentile enables sending a second search request to another replica, if the first one
+	// takes longer than this percentile of recent search latencies, e.g. 95. 0 disables hedged requests.
+	HedgePercentile float64
+	// PipelinedConnections, if not 0, is the number of connections to each index server shared by
+	// concurrent searches using request pipelining, instead of a pool of exclusively used connections.
+	PipelinedConnections int
+	// Shards describes a sharded index. If not empty, Host and Port are not used.
+	Shards []ShardConfig
+	// Partitioning is either PartitionByID or PartitionByHash.
+	Partitioning string
 }
 
 func NewIndexConfig() *IndexConfig {
 	return &IndexConfig{
-		Host: "localhost",
-		Port: 6080,
+		Host:             "localhost",
+		Port:             6080,
+		Partitioning:     PartitionByID,
+		ReplicaSelection: SelectRoundRobin,
+	}
+}
+
+// ShardConfig describes one shard of a sharded index.
+type ShardConfig struct {
+	Host string
+	Port int
+	// Replicas lists addresses of additional index servers with the same data as the shard.
+	Replicas []string
+	// MinID and MaxID is the range of fingerprint IDs owned by the shard when partitioning by ID, 0 means unbounded.
+	MinID uint32
+	MaxID uint32
+}
+
+// Addr returns the address of the shard in the host:port format.
+func (c ShardConfig) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// ContainsID returns true if the fingerprint ID is in the range owned by the shard.
+func (c ShardConfig) ContainsID(id uint32) bool {
+	return id >= c.MinID && (c.MaxID == 0 || id <= c.MaxID)
+}
+
+// ParseAddr parses an index server address in the host:port format.
+func ParseAddr(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q", portStr)
+	}
+	return host, port, nil
+}
+
+// ParseShardConfig parses a shard description in the "host:port" or "host:port=minid-maxid" format.
+// Either end of the ID range can be left empty. Replicas of the shard can be listed as comma-separated
+// addresses, e.g. "host1:port,host2:port=minid-maxid".
+func ParseShardConfig(str string) (ShardConfig, error) {
+	var cfg ShardConfig
+	parts := strings.SplitN(str, "=", 2)
+	addrs := strings.Split(parts[0], ",")
+	for _, addr := range addrs {
+		if _, _, err := ParseAddr(addr); err != nil {
+			return cfg, fmt.Errorf("invalid shard address: %w", err)
+		}
+	}
+	cfg.Host, cfg.Port, _ = ParseAddr(addrs[0])
+	if len(addrs) > 1 {
+		cfg.Replicas = addrs[1:]
+	}
+	if len(parts) == 2 {
+		ids := strings.SplitN(parts[1], "-", 2)
+		if len(ids) != 2 {
+			return cfg, fmt.Errorf("invalid shard ID range %q", parts[1])
+		}
+		if ids[0] != "" {
+			minID, err := strconv.ParseUint(ids[0], 10, 32)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid shard ID range %q", parts[1])
+			}
+			cfg.MinID = uint32(minID)
+		}
+		if ids[1] != "" {
+			maxID, err := strconv.ParseUint(ids[1], 10, 32)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid shard ID range %q", parts[1])
+			}
+			cfg.MaxID = uint32(maxID)
+		}
 	}
+	return cfg, nil
 }
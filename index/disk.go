@@ -0,0 +1,654 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/acoustid/go-acoustid/util/intset"
+	"github.com/acoustid/go-acoustid/util/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+const diskIndexManifestFileName = "manifest.json"
+const diskIndexLockFileName = "LOCK"
+
+// diskIndexMergeFactor is the number of segments of similar size that are merged into one.
+const diskIndexMergeFactor = 10
+
+type diskIndexManifest struct {
+	NextSegmentID uint64            `json:"next_segment_id"`
+	Segments      []uint64          `json:"segments"`
+	Attributes    map[string]string `json:"attributes"`
+}
+
+func newDiskIndexManifest() *diskIndexManifest {
+	return &diskIndexManifest{NextSegmentID: 1, Attributes: make(map[string]string)}
+}
+
+func (m *diskIndexManifest) clone() *diskIndexManifest {
+	m2 := &diskIndexManifest{
+		NextSegmentID: m.NextSegmentID,
+		Segments:      append([]uint64(nil), m.Segments...),
+		Attributes:    make(map[string]string, len(m.Attributes)),
+	}
+	for name, value := range m.Attributes {
+		m2.Attributes[name] = value
+	}
+	return m2
+}
+
+// DiskIndex is a persistent inverted index with the same semantics as MemoryIndex.
+//
+// Each committed transaction is written as a new immutable segment file. The list of segments
+// and index attributes are stored in a manifest file, which is atomically replaced on every change,
+// so after a crash the index always opens in the state of the last successful commit. Files
+// not referenced by the manifest are removed when the index is opened.
+//
+// A document stored in a newer segment hides the same document in older segments. Deleted documents
+// are recorded as tombstones, which hide the document in older segments until the segment holding
+// the tombstone is merged with the oldest one. Segments are merged in the background, so that
+// the number of segments grows logarithmically.
+type DiskIndex struct {
+	fs   vfs.FileSystem
+	lock io.Closer
+
+	// writeMu serializes changes of the manifest, mergeMu serializes merges.
+	writeMu sync.Mutex
+	mergeMu sync.Mutex
+
+	mu       sync.RWMutex
+	closed   bool
+	manifest *diskIndexManifest
+	segments []*segment
+
+	mergeCh chan struct{}
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+// OpenDiskIndex opens the index stored in fs, or creates a new one if the directory is empty.
+func OpenDiskIndex(fs vfs.FileSystem) (*DiskIndex, error) {
+	lock, err := fs.Lock(diskIndexLockFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock index: %w", err)
+	}
+
+	idx := &DiskIndex{
+		fs:      fs,
+		lock:    lock,
+		mergeCh: make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+	}
+
+	err = idx.load()
+	if err != nil {
+		for _, s := range idx.segments {
+			s.release()
+		}
+		lock.Close()
+		return nil, err
+	}
+
+	idx.wg.Add(1)
+	go idx.runMerges()
+	idx.scheduleMerge()
+	return idx, nil
+}
+
+func (idx *DiskIndex) load() error {
+	manifest := newDiskIndexManifest()
+	file, err := idx.fs.OpenFile(diskIndexManifestFileName)
+	if err != nil {
+		if !vfs.IsNotExist(err) {
+			return fmt.Errorf("failed to open manifest: %w", err)
+		}
+	} else {
+		err = json.NewDecoder(file).Decode(manifest)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read manifest: %w", err)
+		}
+		if manifest.Attributes == nil {
+			manifest.Attributes = make(map[string]string)
+		}
+	}
+	idx.manifest = manifest
+
+	for _, id := range manifest.Segments {
+		s, err := openSegment(idx.fs, id)
+		if err != nil {
+			return err
+		}
+		idx.segments = append(idx.segments, s)
+	}
+
+	return idx.removeUnusedFiles()
+}
+
+// removeUnusedFiles removes segments and temporary files left behind by interrupted commits and merges.
+func (idx *DiskIndex) removeUnusedFiles() error {
+	used := map[string]bool{
+		diskIndexManifestFileName: true,
+		diskIndexLockFileName:     true,
+	}
+	for _, id := range idx.manifest.Segments {
+		used[segmentFileName(id)] = true
+	}
+	files, err := idx.fs.ReadDir()
+	if err != nil {
+		return fmt.Errorf("failed to list index files: %w", err)
+	}
+	for _, file := range files {
+		if file.IsDir() || used[file.Name()] {
+			continue
+		}
+		log.Infof("Removing unused index file %v", file.Name())
+		err = idx.fs.Remove(file.Name())
+		if err != nil {
+			return fmt.Errorf("failed to remove unused index file: %w", err)
+		}
+	}
+	return nil
+}
+
+func (idx *DiskIndex) IsOK() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return !idx.closed
+}
+
+// Close waits for the running merge to finish and releases the index files.
+func (idx *DiskIndex) Close(ctx context.Context) error {
+	idx.mu.Lock()
+	if idx.closed {
+		idx.mu.Unlock()
+		return nil
+	}
+	idx.closed = true
+	idx.mu.Unlock()
+
+	close(idx.closeCh)
+	idx.wg.Wait()
+
+	idx.writeMu.Lock()
+	defer idx.writeMu.Unlock()
+	for _, s := range idx.segments {
+		s.release()
+	}
+	idx.segments = nil
+	return idx.lock.Close()
+}
+
+func (idx *DiskIndex) GetAttribute(ctx context.Context, name string) (string, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.closed {
+		return "", ErrIndexClosed
+	}
+	return idx.manifest.Attributes[name], nil
+}
+
+func (idx *DiskIndex) SetAttribute(ctx context.Context, name string, value string) error {
+	idx.writeMu.Lock()
+	defer idx.writeMu.Unlock()
+	if !idx.IsOK() {
+		return ErrIndexClosed
+	}
+	manifest := idx.manifest.clone()
+	manifest.Attributes[name] = value
+	err := idx.writeManifest(manifest)
+	if err != nil {
+		return err
+	}
+	idx.mu.Lock()
+	idx.manifest = manifest
+	idx.mu.Unlock()
+	return nil
+}
+
+// NumSegments returns the number of segments the index currently consists of.
+func (idx *DiskIndex) NumSegments() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.segments)
+}
+
+// Search returns the same results as MemoryIndex.Search would for the same documents.
+func (idx *DiskIndex) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	segments, err := idx.acquireSegments()
+	if err != nil {
+		return nil, err
+	}
+	defer releaseSegments(segments)
+
+	hits := make(map[uint32]uint32)
+	for _, hash := range uniqueQueryHashes(in.GetHashes()) {
+		for i, s := range segments {
+			ids, err := s.postings(hash)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read segment %v: %w", s.id, err)
+			}
+			for _, id := range ids {
+				if !isHiddenDoc(segments, i, id) {
+					hits[id]++
+				}
+			}
+		}
+	}
+
+	results := make([]*pb.Result, 0, len(hits))
+	for id, count := range hits {
+		results = append(results, &pb.Result{Id: id, Hits: count})
+	}
+	sortResults(results)
+	return &pb.SearchResponse{Results: results}, nil
+}
+
+// Insert adds the fingerprints to the index in one transaction.
+func (idx *DiskIndex) Insert(ctx context.Context, in *pb.InsertRequest) (*pb.InsertResponse, error) {
+	err := InsertFingerprints(ctx, idx, in.GetFingerprints())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.InsertResponse{}, nil
+}
+
+// Delete removes the fingerprints from the index in one transaction.
+func (idx *DiskIndex) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	err := DeleteFingerprints(ctx, idx, in.GetIds())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+// BeginTx starts a transaction. Inserted and deleted documents are kept in memory until the transaction is committed.
+func (idx *DiskIndex) BeginTx(ctx context.Context) (Tx, error) {
+	if !idx.IsOK() {
+		return nil, ErrIndexClosed
+	}
+	return newBufferedTx(idx.commit), nil
+}
+
+func (idx *DiskIndex) commit(docs map[uint32][]uint32) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	idx.writeMu.Lock()
+	defer idx.writeMu.Unlock()
+	if !idx.IsOK() {
+		return ErrIndexClosed
+	}
+
+	manifest := idx.manifest.clone()
+	id := manifest.NextSegmentID
+	manifest.NextSegmentID++
+
+	s, err := idx.writeSegment(id, docs)
+	if err != nil {
+		return err
+	}
+
+	maxID, _ := strconv.ParseUint(manifest.Attributes[maxDocumentIDAttribute], 10, 32)
+	for docID, hashes := range docs {
+		if hashes != nil && uint64(docID) > maxID {
+			maxID = uint64(docID)
+		}
+	}
+	manifest.Attributes[maxDocumentIDAttribute] = strconv.FormatUint(maxID, 10)
+	manifest.Segments = append(manifest.Segments, id)
+
+	err = idx.writeManifest(manifest)
+	if err != nil {
+		s.markObsolete()
+		s.release()
+		return err
+	}
+
+	idx.mu.Lock()
+	idx.manifest = manifest
+	idx.segments = append(idx.segments[:len(idx.segments):len(idx.segments)], s)
+	idx.mu.Unlock()
+
+	idx.scheduleMerge()
+	return nil
+}
+
+func (idx *DiskIndex) writeSegment(id uint64, docs map[uint32][]uint32) (*segment, error) {
+	postings := make(map[uint32][]uint32)
+	for docID, hashes := range docs {
+		for _, hash := range hashes {
+			postings[hash] = append(postings[hash], docID)
+		}
+	}
+	hashes := make([]uint32, 0, len(postings))
+	for hash := range postings {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+
+	w, err := newSegmentWriter(idx.fs, segmentFileName(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create segment: %w", err)
+	}
+	defer w.close()
+	for _, hash := range hashes {
+		ids := postings[hash]
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		err = w.add(hash, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write segment: %w", err)
+		}
+	}
+	for docID, hashes := range docs {
+		if hashes == nil {
+			w.deleteDoc(docID)
+		} else {
+			w.addDoc(docID)
+		}
+	}
+	err = w.commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write segment: %w", err)
+	}
+	return openSegment(idx.fs, id)
+}
+
+func (idx *DiskIndex) writeManifest(manifest *diskIndexManifest) error {
+	err := vfs.WriteFile(idx.fs, diskIndexManifestFileName, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(manifest)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+func (idx *DiskIndex) acquireSegments() ([]*segment, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.closed {
+		return nil, ErrIndexClosed
+	}
+	segments := make([]*segment, len(idx.segments))
+	for i, s := range idx.segments {
+		s.acquire()
+		segments[i] = s
+	}
+	return segments, nil
+}
+
+func releaseSegments(segments []*segment) {
+	for _, s := range segments {
+		err := s.release()
+		if err != nil {
+			log.Errorf("Failed to release segment %v: %v", s.id, err)
+		}
+	}
+}
+
+// isHiddenDoc returns true if the document in the i-th segment was replaced by a newer segment.
+func isHiddenDoc(segments []*segment, i int, id uint32) bool {
+	for _, s := range segments[i+1:] {
+		if s.containsDoc(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (idx *DiskIndex) scheduleMerge() {
+	select {
+	case idx.mergeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (idx *DiskIndex) runMerges() {
+	defer idx.wg.Done()
+	for {
+		select {
+		case <-idx.closeCh:
+			return
+		case <-idx.mergeCh:
+		}
+		for {
+			merged, err := idx.maybeMerge()
+			if err != nil {
+				log.Errorf("Failed to merge index segments: %v", err)
+				break
+			}
+			if !merged {
+				break
+			}
+		}
+	}
+}
+
+// maybeMerge merges one run of segments of similar size, if there is one.
+func (idx *DiskIndex) maybeMerge() (bool, error) {
+	idx.mergeMu.Lock()
+	defer idx.mergeMu.Unlock()
+
+	segments, err := idx.acquireSegments()
+	if err != nil {
+		if err == ErrIndexClosed {
+			return false, nil
+		}
+		return false, err
+	}
+	defer releaseSegments(segments)
+
+	start, end := findMergeRun(segments)
+	if start == end {
+		return false, nil
+	}
+	return true, idx.mergeSegments(segments, start, end)
+}
+
+// Optimize merges all segments into one.
+func (idx *DiskIndex) Optimize(ctx context.Context) error {
+	idx.mergeMu.Lock()
+	defer idx.mergeMu.Unlock()
+
+	segments, err := idx.acquireSegments()
+	if err != nil {
+		return err
+	}
+	defer releaseSegments(segments)
+
+	if len(segments) < 2 {
+		return nil
+	}
+	return idx.mergeSegments(segments, 0, len(segments))
+}
+
+func segmentTier(numDocs int) int {
+	tier := 0
+	for numDocs >= diskIndexMergeFactor {
+		numDocs /= diskIndexMergeFactor
+		tier++
+	}
+	return tier
+}
+
+// findMergeRun finds the newest run of consecutive segments that contains diskIndexMergeFactor segments
+// of the same size tier and no larger segments. Lower tiers are checked first. Smaller segments within
+// the run, e.g. from commits that only deleted a few documents, are merged along with it.
+func findMergeRun(segments []*segment) (int, int) {
+	maxTier := 0
+	for _, s := range segments {
+		if tier := segmentTier(s.numDocs); tier > maxTier {
+			maxTier = tier
+		}
+	}
+	for tier := 0; tier <= maxTier; tier++ {
+		end := len(segments)
+		for end > 0 {
+			if segmentTier(segments[end-1].numDocs) > tier {
+				end--
+				continue
+			}
+			count := 0
+			start := end
+			for start > 0 && segmentTier(segments[start-1].numDocs) <= tier {
+				start--
+				if segmentTier(segments[start].numDocs) == tier {
+					count++
+					if count == diskIndexMergeFactor {
+						return start, end
+					}
+				}
+			}
+			end = start
+		}
+	}
+	return 0, 0
+}
+
+// mergeSegments replaces segments[start:end] with one segment. Postings of documents hidden
+// by newer segments are dropped, and so are tombstones if the merged segments include the oldest one.
+func (idx *DiskIndex) mergeSegments(segments []*segment, start, end int) error {
+	idx.writeMu.Lock()
+	if !idx.IsOK() {
+		idx.writeMu.Unlock()
+		return ErrIndexClosed
+	}
+	manifest := idx.manifest.clone()
+	id := manifest.NextSegmentID
+	manifest.NextSegmentID++
+	idx.mu.Lock()
+	idx.manifest = manifest
+	idx.mu.Unlock()
+	idx.writeMu.Unlock()
+
+	merged, err := idx.writeMergedSegment(id, segments, start, end)
+	if err != nil {
+		return err
+	}
+
+	idx.writeMu.Lock()
+	defer idx.writeMu.Unlock()
+
+	if !idx.IsOK() {
+		merged.markObsolete()
+		merged.release()
+		return ErrIndexClosed
+	}
+
+	// Commits only append new segments, so the merged ones are still in the same order.
+	pos := -1
+	for i, s := range idx.segments {
+		if s == segments[start] {
+			pos = i
+			break
+		}
+	}
+	if pos == -1 || pos+end-start > len(idx.segments) {
+		merged.markObsolete()
+		merged.release()
+		return fmt.Errorf("merged segments are no longer in the index")
+	}
+
+	newSegments := make([]*segment, 0, len(idx.segments)-(end-start)+1)
+	newSegments = append(newSegments, idx.segments[:pos]...)
+	newSegments = append(newSegments, merged)
+	newSegments = append(newSegments, idx.segments[pos+end-start:]...)
+
+	manifest = idx.manifest.clone()
+	manifest.Segments = make([]uint64, len(newSegments))
+	for i, s := range newSegments {
+		manifest.Segments[i] = s.id
+	}
+	err = idx.writeManifest(manifest)
+	if err != nil {
+		merged.markObsolete()
+		merged.release()
+		return err
+	}
+
+	idx.mu.Lock()
+	idx.manifest = manifest
+	idx.segments = newSegments
+	idx.mu.Unlock()
+
+	for _, s := range segments[start:end] {
+		s.markObsolete()
+		s.release()
+	}
+	log.Debugf("Merged %d index segments into segment %v", end-start, id)
+	return nil
+}
+
+func (idx *DiskIndex) writeMergedSegment(id uint64, segments []*segment, start, end int) (*segment, error) {
+	w, err := newSegmentWriter(idx.fs, segmentFileName(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create segment: %w", err)
+	}
+	defer w.close()
+
+	iterators := make([]*segmentIterator, end-start)
+	for i, s := range segments[start:end] {
+		iterators[i] = s.iterator()
+		// Newer segments override the state of documents in older ones.
+		w.docs.Difference(s.deleted)
+		w.deleted.Difference(s.docs)
+		w.docs.Union(s.docs)
+		w.deleted.Union(s.deleted)
+	}
+	if start == 0 {
+		// There are no older segments in which deleted documents would need to be hidden.
+		w.deleted = intset.NewSparseBitSet(0)
+	}
+
+	var ids []uint32
+	for {
+		found := false
+		var hash uint32
+		for _, it := range iterators {
+			if !it.done() && (!found || it.hash() < hash) {
+				hash = it.hash()
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+		ids = ids[:0]
+		for i, it := range iterators {
+			if it.done() || it.hash() != hash {
+				continue
+			}
+			postings, err := it.next()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read segment %v: %w", segments[start+i].id, err)
+			}
+			for _, docID := range postings {
+				if !isHiddenDoc(segments, start+i, docID) {
+					ids = append(ids, docID)
+				}
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		err = w.add(hash, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write segment: %w", err)
+		}
+	}
+
+	err = w.commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write segment: %w", err)
+	}
+	return openSegment(idx.fs, id)
+}
@@ -0,0 +1,322 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/acoustid/go-acoustid/util/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateTestFingerprints(rng *rand.Rand, firstID, count int) []*pb.Fingerprint {
+	fingerprints := make([]*pb.Fingerprint, count)
+	for i := range fingerprints {
+		hashes := make([]uint32, 5+rng.Intn(20))
+		for j := range hashes {
+			hashes[j] = uint32(rng.Intn(200)) << 4
+		}
+		fingerprints[i] = &pb.Fingerprint{Id: uint32(firstID + i), Hashes: hashes}
+	}
+	return fingerprints
+}
+
+func assertSameSearchResults(t *testing.T, rng *rand.Rand, expected, actual Searcher) {
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		query := generateTestFingerprints(rng, 0, 1)[0].Hashes
+		expectedResponse, err := expected.Search(ctx, &pb.SearchRequest{Hashes: query})
+		require.NoError(t, err)
+		actualResponse, err := actual.Search(ctx, &pb.SearchRequest{Hashes: query})
+		require.NoError(t, err)
+		assert.Equal(t, expectedResponse.Results, actualResponse.Results, "query %v", query)
+	}
+}
+
+func TestDiskIndex(t *testing.T) {
+	ctx := context.Background()
+	fs := vfs.CreateMemDir()
+
+	idx, err := OpenDiskIndex(fs)
+	require.NoError(t, err)
+
+	_, err = OpenDiskIndex(fs)
+	assert.Error(t, err, "index should be locked")
+
+	_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{
+		{Id: 1, Hashes: []uint32{0x100, 0x200, 0x300}},
+		{Id: 2, Hashes: []uint32{0x200, 0x300, 0x400, 0x300}},
+	}})
+	require.NoError(t, err)
+	_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{
+		{Id: 3, Hashes: []uint32{0x10f}},
+		{Id: 1, Hashes: []uint32{0x400}},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, idx.SetAttribute(ctx, "foo", "bar"))
+
+	check := func(idx *DiskIndex) {
+		response, err := idx.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100, 0x200, 0x300, 0x400}})
+		require.NoError(t, err)
+		assert.Equal(t, []*pb.Result{{Id: 2, Hits: 3}, {Id: 1, Hits: 1}, {Id: 3, Hits: 1}}, response.Results)
+
+		lastID, err := GetLastFingerprintID(ctx, idx)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(3), lastID)
+
+		value, err := idx.GetAttribute(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "bar", value)
+	}
+
+	check(idx)
+	assert.Equal(t, 2, idx.NumSegments())
+
+	require.NoError(t, idx.Optimize(ctx))
+	assert.Equal(t, 1, idx.NumSegments())
+	check(idx)
+
+	require.NoError(t, idx.Close(ctx))
+	_, err = idx.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100}})
+	assert.Equal(t, ErrIndexClosed, err)
+
+	idx, err = OpenDiskIndex(fs)
+	require.NoError(t, err)
+	defer idx.Close(ctx)
+	assert.Equal(t, 1, idx.NumSegments())
+	check(idx)
+}
+
+func TestDiskIndex_SameAsMemoryIndex(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1234))
+	fs, err := vfs.CreateTempDir()
+	require.NoError(t, err)
+	defer fs.Close()
+
+	idx, err := OpenDiskIndex(fs)
+	require.NoError(t, err)
+	memIdx := NewMemoryIndex()
+
+	for i := 0; i < 25; i++ {
+		fingerprints := generateTestFingerprints(rng, 1+i*20, 20)
+		// replace some of the previously inserted documents
+		fingerprints = append(fingerprints, generateTestFingerprints(rng, 1+rng.Intn(1+i*20), 3)...)
+		_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: fingerprints})
+		require.NoError(t, err)
+		_, err = memIdx.Insert(ctx, &pb.InsertRequest{Fingerprints: fingerprints})
+		require.NoError(t, err)
+		// and delete some
+		var ids []uint32
+		for j := 0; j < 3; j++ {
+			ids = append(ids, uint32(1+rng.Intn(1+i*20)))
+		}
+		_, err = idx.Delete(ctx, &pb.DeleteRequest{Ids: ids})
+		require.NoError(t, err)
+		_, err = memIdx.Delete(ctx, &pb.DeleteRequest{Ids: ids})
+		require.NoError(t, err)
+	}
+	assertSameSearchResults(t, rng, memIdx, idx)
+
+	for merged := true; merged; {
+		merged, err = idx.maybeMerge()
+		require.NoError(t, err)
+	}
+	assert.True(t, idx.NumSegments() < 25, "segments should be merged, got %v", idx.NumSegments())
+	assertSameSearchResults(t, rng, memIdx, idx)
+
+	require.NoError(t, idx.Optimize(ctx))
+	assert.Equal(t, 1, idx.NumSegments())
+	assertSameSearchResults(t, rng, memIdx, idx)
+
+	require.NoError(t, idx.Close(ctx))
+	idx, err = OpenDiskIndex(fs)
+	require.NoError(t, err)
+	defer idx.Close(ctx)
+	assertSameSearchResults(t, rng, memIdx, idx)
+
+	files, err := fs.ReadDir()
+	require.NoError(t, err)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.ElementsMatch(t, []string{"LOCK", "manifest.json", segmentFileName(idx.manifest.Segments[0])}, names)
+}
+
+func TestDiskIndex_Delete(t *testing.T) {
+	ctx := context.Background()
+	fs := vfs.CreateMemDir()
+
+	idx, err := OpenDiskIndex(fs)
+	require.NoError(t, err)
+	defer func() { idx.Close(ctx) }()
+
+	search := func() []*pb.Result {
+		response, err := idx.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100, 0x200, 0x300}})
+		require.NoError(t, err)
+		return response.Results
+	}
+
+	_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{
+		{Id: 1, Hashes: []uint32{0x100}},
+		{Id: 2, Hashes: []uint32{0x200}},
+		{Id: 3, Hashes: []uint32{0x300}},
+	}})
+	require.NoError(t, err)
+	_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 4, Hashes: []uint32{0x100}}}})
+	require.NoError(t, err)
+	_, err = idx.Delete(ctx, &pb.DeleteRequest{Ids: []uint32{1, 2}})
+	require.NoError(t, err)
+	tx, err := idx.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(ctx, 4))
+	require.NoError(t, tx.Insert(ctx, 2, []uint32{0x300}))
+	require.NoError(t, tx.Commit(ctx))
+
+	expected := []*pb.Result{{Id: 2, Hits: 1}, {Id: 3, Hits: 1}}
+	assert.Equal(t, expected, search())
+	lastID, err := GetLastFingerprintID(ctx, idx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(4), lastID)
+
+	// tombstones are kept when merging newer segments, they still need to hide documents in the oldest one
+	segments, err := idx.acquireSegments()
+	require.NoError(t, err)
+	require.NoError(t, idx.mergeSegments(segments, 1, 4))
+	releaseSegments(segments)
+	assert.Equal(t, 2, idx.NumSegments())
+	assert.Equal(t, expected, search())
+	segments, err = idx.acquireSegments()
+	require.NoError(t, err)
+	assert.True(t, segments[1].deleted.Contains(1))
+	assert.True(t, segments[1].deleted.Contains(4))
+	assert.False(t, segments[1].deleted.Contains(2))
+	assert.True(t, segments[1].docs.Contains(2))
+	releaseSegments(segments)
+
+	require.NoError(t, idx.Close(ctx))
+	idx, err = OpenDiskIndex(fs)
+	require.NoError(t, err)
+	assert.Equal(t, expected, search())
+
+	// and dropped when merging with the oldest segment
+	require.NoError(t, idx.Optimize(ctx))
+	assert.Equal(t, 1, idx.NumSegments())
+	assert.Equal(t, expected, search())
+	segments, err = idx.acquireSegments()
+	require.NoError(t, err)
+	assert.Equal(t, 0, segments[0].deleted.Len())
+	assert.Equal(t, 2, segments[0].numDocs)
+	releaseSegments(segments)
+}
+
+func TestDiskIndex_Recovery(t *testing.T) {
+	ctx := context.Background()
+	fs := vfs.CreateMemDir()
+
+	idx, err := OpenDiskIndex(fs)
+	require.NoError(t, err)
+	_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 1, Hashes: []uint32{0x100}}}})
+	require.NoError(t, err)
+	require.NoError(t, idx.Close(ctx))
+
+	// a segment written by a commit which didn't get to update the manifest
+	w, err := newSegmentWriter(fs, segmentFileName(100))
+	require.NoError(t, err)
+	require.NoError(t, w.add(0x200, []uint32{2}))
+	w.addDoc(2)
+	require.NoError(t, w.commit())
+
+	idx, err = OpenDiskIndex(fs)
+	require.NoError(t, err)
+	defer idx.Close(ctx)
+
+	response, err := idx.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100, 0x200}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.Results)
+
+	_, err = fs.OpenFile(segmentFileName(100))
+	assert.True(t, vfs.IsNotExist(err), "unused segment should be removed")
+}
+
+func TestDiskIndex_CorruptSegment(t *testing.T) {
+	ctx := context.Background()
+	fs := vfs.CreateMemDir()
+
+	idx, err := OpenDiskIndex(fs)
+	require.NoError(t, err)
+	_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 1, Hashes: []uint32{0x100}}}})
+	require.NoError(t, err)
+	name := segmentFileName(idx.manifest.Segments[0])
+	require.NoError(t, idx.Close(ctx))
+
+	file, err := fs.OpenFile(name)
+	require.NoError(t, err)
+	data := make([]byte, file.Size())
+	_, err = file.ReadAt(data, 0)
+	require.NoError(t, err)
+	data[len(segmentMagic)] ^= 0xff
+	err = vfs.WriteFile(fs, name, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	require.NoError(t, err)
+
+	_, err = OpenDiskIndex(fs)
+	assert.Error(t, err)
+}
+
+func TestDiskIndex_UnsupportedSegmentVersion(t *testing.T) {
+	ctx := context.Background()
+	fs := vfs.CreateMemDir()
+
+	idx, err := OpenDiskIndex(fs)
+	require.NoError(t, err)
+	_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 1, Hashes: []uint32{0x100}}}})
+	require.NoError(t, err)
+	name := segmentFileName(idx.manifest.Segments[0])
+	require.NoError(t, idx.Close(ctx))
+
+	// a segment in the old layout, without deleted documents, only the magic matters
+	data := append(append([]byte{}, segmentMagicV1...), make([]byte, 32-len(segmentMagicV1))...)
+	data = append(data, segmentMagicV1...)
+	err = vfs.WriteFile(fs, name, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	require.NoError(t, err)
+
+	_, err = OpenDiskIndex(fs)
+	assert.True(t, errors.Is(err, errUnsupportedSegmentVersion), "unexpected error %v", err)
+}
+
+func TestFindMergeRun(t *testing.T) {
+	var segments []*segment
+	for i := 0; i < 9; i++ {
+		segments = append(segments, &segment{numDocs: 10000})
+	}
+	for i := 0; i < 9; i++ {
+		segments = append(segments, &segment{numDocs: 5})
+	}
+	start, end := findMergeRun(segments)
+	assert.Equal(t, 0, end-start)
+
+	segments = append(segments, &segment{numDocs: 1})
+	start, end = findMergeRun(segments)
+	assert.Equal(t, 9, start)
+	assert.Equal(t, 19, end)
+
+	// small segments in between don't prevent merging larger ones
+	segments = segments[:0]
+	for i := 0; i < 12; i++ {
+		segments = append(segments, &segment{numDocs: 50}, &segment{numDocs: 2})
+	}
+	start, end = findMergeRun(segments)
+	assert.Equal(t, 4, start)
+	assert.Equal(t, 24, end)
+}
@@ -3,6 +3,8 @@ package index
 import (
 	"context"
 	"strconv"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
 )
 
 type Index interface {
@@ -15,12 +17,18 @@ type Index interface {
 
 type Tx interface {
 	Insert(ctx context.Context, id uint32, hashes []uint32) error
+	Delete(ctx context.Context, id uint32) error
 	Commit(ctx context.Context) error
 	Rollback(ctx context.Context) error
 }
 
-func GetLastFingerprintID(ctx context.Context, idx Index) (uint32, error) {
-	strValue, err := idx.GetAttribute(ctx, "max_document_id")
+// AttributeGetter is implemented by indexes and connection pools that can read index attributes.
+type AttributeGetter interface {
+	GetAttribute(ctx context.Context, name string) (string, error)
+}
+
+func GetLastFingerprintID(ctx context.Context, idx AttributeGetter) (uint32, error) {
+	strValue, err := idx.GetAttribute(ctx, maxDocumentIDAttribute)
 	if err != nil {
 		return 0, err
 	}
@@ -33,3 +41,41 @@ func GetLastFingerprintID(ctx context.Context, idx Index) (uint32, error) {
 	}
 	return uint32(value), nil
 }
+
+// InsertFingerprints adds the fingerprints to the index in one transaction.
+func InsertFingerprints(ctx context.Context, idx Index, fingerprints []*pb.Fingerprint) error {
+	if len(fingerprints) == 0 {
+		return nil
+	}
+	tx, err := idx.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	for _, fingerprint := range fingerprints {
+		err = tx.Insert(ctx, fingerprint.GetId(), fingerprint.GetHashes())
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// DeleteFingerprints removes the fingerprints from the index in one transaction.
+func DeleteFingerprints(ctx context.Context, idx Index, ids []uint32) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	tx, err := idx.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		err = tx.Delete(ctx, id)
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
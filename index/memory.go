@@ -0,0 +1,256 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+)
+
+// QueryHashBits is the number of the most significant bits of fingerprint hashes used in index queries.
+const QueryHashBits = 28
+
+// QueryHashMask clears the least significant bits of fingerprint hashes, which are not used in index queries.
+const QueryHashMask = ((1 << QueryHashBits) - 1) << (32 - QueryHashBits)
+
+const maxDocumentIDAttribute = "max_document_id"
+
+var ErrIndexClosed = errors.New("index is closed")
+
+// MemoryIndex is an inverted index mapping query hashes to document IDs, kept entirely in memory.
+// It's meant for tests and small deployments that don't want to run a separate index server.
+// Hashes are masked with QueryHashMask, both when inserting and searching.
+type MemoryIndex struct {
+	mu         sync.RWMutex
+	closed     bool
+	postings   map[uint32][]uint32
+	docs       map[uint32][]uint32
+	attributes map[string]string
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		postings:   make(map[uint32][]uint32),
+		docs:       make(map[uint32][]uint32),
+		attributes: make(map[string]string),
+	}
+}
+
+func (idx *MemoryIndex) IsOK() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return !idx.closed
+}
+
+func (idx *MemoryIndex) Close(ctx context.Context) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.closed = true
+	return nil
+}
+
+func (idx *MemoryIndex) GetAttribute(ctx context.Context, name string) (string, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.closed {
+		return "", ErrIndexClosed
+	}
+	return idx.attributes[name], nil
+}
+
+func (idx *MemoryIndex) SetAttribute(ctx context.Context, name string, value string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.closed {
+		return ErrIndexClosed
+	}
+	idx.attributes[name] = value
+	return nil
+}
+
+// Len returns the number of documents in the index.
+func (idx *MemoryIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Search returns documents containing at least one of the hashes. Hits is the number of distinct
+// query hashes found in the document. Results are ordered by hits, the best match first.
+func (idx *MemoryIndex) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.closed {
+		return nil, ErrIndexClosed
+	}
+
+	hits := make(map[uint32]uint32)
+	for _, hash := range uniqueQueryHashes(in.GetHashes()) {
+		for _, id := range idx.postings[hash] {
+			hits[id]++
+		}
+	}
+
+	results := make([]*pb.Result, 0, len(hits))
+	for id, count := range hits {
+		results = append(results, &pb.Result{Id: id, Hits: count})
+	}
+	sortResults(results)
+	return &pb.SearchResponse{Results: results}, nil
+}
+
+// Insert adds the fingerprints to the index in one transaction.
+func (idx *MemoryIndex) Insert(ctx context.Context, in *pb.InsertRequest) (*pb.InsertResponse, error) {
+	err := InsertFingerprints(ctx, idx, in.GetFingerprints())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.InsertResponse{}, nil
+}
+
+// Delete removes the fingerprints from the index in one transaction.
+func (idx *MemoryIndex) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	err := DeleteFingerprints(ctx, idx, in.GetIds())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+// BeginTx starts a transaction. Changes become visible to searches only after commit.
+// Inserting a document that is already in the index replaces it. Deleting a document that
+// is not in the index is not an error.
+func (idx *MemoryIndex) BeginTx(ctx context.Context) (Tx, error) {
+	if !idx.IsOK() {
+		return nil, ErrIndexClosed
+	}
+	return newBufferedTx(idx.commit), nil
+}
+
+func (idx *MemoryIndex) commit(docs map[uint32][]uint32) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.closed {
+		return ErrIndexClosed
+	}
+
+	maxID, _ := strconv.ParseUint(idx.attributes[maxDocumentIDAttribute], 10, 32)
+	updated := false
+	for id, hashes := range docs {
+		idx.remove(id)
+		if hashes == nil {
+			continue
+		}
+		updated = true
+		for _, hash := range hashes {
+			idx.postings[hash] = append(idx.postings[hash], id)
+		}
+		idx.docs[id] = hashes
+		if uint64(id) > maxID {
+			maxID = uint64(id)
+		}
+	}
+	if updated {
+		idx.attributes[maxDocumentIDAttribute] = strconv.FormatUint(maxID, 10)
+	}
+	return nil
+}
+
+func (idx *MemoryIndex) remove(id uint32) {
+	hashes, exists := idx.docs[id]
+	if !exists {
+		return
+	}
+	for _, hash := range hashes {
+		ids := idx.postings[hash]
+		for i, x := range ids {
+			if x == id {
+				ids = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(idx.postings, hash)
+		} else {
+			idx.postings[hash] = ids
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// bufferedTx collects changed documents in memory and passes them to the commit function at once.
+// Deleted documents have nil hashes, inserted documents always have a non-nil slice.
+type bufferedTx struct {
+	commit func(docs map[uint32][]uint32) error
+	docs   map[uint32][]uint32
+	done   bool
+}
+
+func newBufferedTx(commit func(docs map[uint32][]uint32) error) *bufferedTx {
+	return &bufferedTx{commit: commit, docs: make(map[uint32][]uint32)}
+}
+
+func (tx *bufferedTx) Insert(ctx context.Context, id uint32, hashes []uint32) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.docs[id] = uniqueQueryHashes(hashes)
+	return nil
+}
+
+func (tx *bufferedTx) Delete(ctx context.Context, id uint32) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.docs[id] = nil
+	return nil
+}
+
+func (tx *bufferedTx) Commit(ctx context.Context) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	err := tx.commit(tx.docs)
+	if err != nil {
+		return err
+	}
+	tx.done = true
+	tx.docs = nil
+	return nil
+}
+
+func (tx *bufferedTx) Rollback(ctx context.Context) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.docs = nil
+	return nil
+}
+
+// uniqueQueryHashes masks the hashes with QueryHashMask and removes duplicates.
+func uniqueQueryHashes(hashes []uint32) []uint32 {
+	seen := make(map[uint32]struct{}, len(hashes))
+	unique := make([]uint32, 0, len(hashes))
+	for _, hash := range hashes {
+		hash &= QueryHashMask
+		if _, exists := seen[hash]; !exists {
+			seen[hash] = struct{}{}
+			unique = append(unique, hash)
+		}
+	}
+	return unique
+}
+
+// sortResults orders search results by hits, results with the same number of hits by document ID.
+func sortResults(results []*pb.Result) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Hits != results[j].Hits {
+			return results[i].Hits > results[j].Hits
+		}
+		return results[i].Id < results[j].Id
+	})
+}
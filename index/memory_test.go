@@ -0,0 +1,131 @@
+package index
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryIndex(t *testing.T) {
+	ctx := context.Background()
+	idx := NewMemoryIndex()
+
+	lastID, err := GetLastFingerprintID(ctx, idx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), lastID)
+
+	_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{
+		{Id: 1, Hashes: []uint32{0x100, 0x200, 0x300}},
+		{Id: 2, Hashes: []uint32{0x200, 0x300, 0x400, 0x300}},
+		{Id: 3, Hashes: []uint32{0x500}},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, 3, idx.Len())
+
+	lastID, err = GetLastFingerprintID(ctx, idx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), lastID)
+
+	response, err := idx.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x200, 0x300, 0x400, 0x400}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 2, Hits: 3}, {Id: 1, Hits: 2}}, response.Results)
+
+	// the lowest 4 bits are ignored
+	response, err = idx.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10f}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.Results)
+
+	response, err = idx.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x600}})
+	require.NoError(t, err)
+	assert.Empty(t, response.Results)
+}
+
+func TestMemoryIndex_Tx(t *testing.T) {
+	ctx := context.Background()
+	idx := NewMemoryIndex()
+
+	tx, err := idx.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Insert(ctx, 1, []uint32{0x100}))
+	response, err := idx.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100}})
+	require.NoError(t, err)
+	assert.Empty(t, response.Results, "uncommitted documents should not be visible")
+	require.NoError(t, tx.Rollback(ctx))
+	assert.Equal(t, ErrTxDone, tx.Commit(ctx))
+	assert.Equal(t, 0, idx.Len())
+
+	tx, err = idx.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Insert(ctx, 5, []uint32{0x100, 0x200}))
+	require.NoError(t, tx.Commit(ctx))
+
+	tx, err = idx.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Insert(ctx, 4, []uint32{0x300}))
+	require.NoError(t, tx.Insert(ctx, 5, []uint32{0x200, 0x300}))
+	require.NoError(t, tx.Commit(ctx))
+
+	response, err = idx.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100, 0x200, 0x300}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 5, Hits: 2}, {Id: 4, Hits: 1}}, response.Results)
+
+	lastID, err := GetLastFingerprintID(ctx, idx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(5), lastID)
+}
+
+func TestMemoryIndex_Delete(t *testing.T) {
+	ctx := context.Background()
+	idx := NewMemoryIndex()
+
+	_, err := idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{
+		{Id: 1, Hashes: []uint32{0x100, 0x200}},
+		{Id: 2, Hashes: []uint32{0x200}},
+		{Id: 3, Hashes: []uint32{0x300}},
+	}})
+	require.NoError(t, err)
+
+	_, err = idx.Delete(ctx, &pb.DeleteRequest{Ids: []uint32{3, 4}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, idx.Len())
+
+	tx, err := idx.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(ctx, 1))
+	require.NoError(t, tx.Insert(ctx, 1, []uint32{0x300}))
+	require.NoError(t, tx.Insert(ctx, 2, []uint32{0x100}))
+	require.NoError(t, tx.Delete(ctx, 2))
+	require.NoError(t, tx.Commit(ctx))
+	assert.Equal(t, ErrTxDone, tx.Delete(ctx, 1))
+
+	response, err := idx.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100, 0x200, 0x300}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.Results)
+	assert.Equal(t, 1, idx.Len())
+
+	lastID, err := GetLastFingerprintID(ctx, idx)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), lastID, "deletes should not change the last fingerprint ID")
+}
+
+func TestMemoryIndex_Attributes(t *testing.T) {
+	ctx := context.Background()
+	idx := NewMemoryIndex()
+
+	value, err := idx.GetAttribute(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "", value)
+
+	require.NoError(t, idx.SetAttribute(ctx, "foo", "bar"))
+	value, err = idx.GetAttribute(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", value)
+
+	require.NoError(t, idx.Close(ctx))
+	assert.False(t, idx.IsOK())
+	_, err = idx.GetAttribute(ctx, "foo")
+	assert.Equal(t, ErrIndexClosed, err)
+}
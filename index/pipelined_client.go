@@ -0,0 +1,345 @@
+package index
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+)
+
+var ErrClientClosed = errors.New("index client is closed")
+
+// pipelinedCall is one request sent over a PipelinedIndexClient connection.
+type pipelinedCall struct {
+	ctx      context.Context
+	request  string
+	response string
+	err      error
+	done     chan struct{}
+}
+
+func (call *pipelinedCall) finish(response string, err error) {
+	call.response = response
+	call.err = err
+	close(call.done)
+}
+
+// PipelinedIndexClient lets many goroutines share one connection to the index server.
+// Requests are written without waiting for responses to the previous ones and since the server
+// responds in the same order, responses are matched to requests by their position.
+//
+// Requests whose context is done before they are written are not sent at all. If the context is done
+// while waiting for the response, the caller returns immediately and the response is discarded when it arrives.
+// Any I/O or protocol error closes the connection and fails all pending requests. The connection deadline is
+// the earliest deadline of the pending requests, so a server that stops responding is also treated as an error.
+//
+// Transactions are bound to the connection on the server side, so they are not supported here, use IndexClient for updates.
+type PipelinedIndexClient struct {
+	conn     net.Conn
+	requests chan *pipelinedCall
+	closing  chan struct{}
+
+	mu       sync.Mutex
+	pending  []*pipelinedCall
+	deadline time.Time
+	err      error
+	wg       sync.WaitGroup
+}
+
+func NewPipelinedIndexClient(conn net.Conn) *PipelinedIndexClient {
+	c := &PipelinedIndexClient{
+		conn:     conn,
+		requests: make(chan *pipelinedCall),
+		closing:  make(chan struct{}),
+	}
+	c.wg.Add(2)
+	go c.writeLoop()
+	go c.readLoop()
+	return c
+}
+
+func ConnectPipelined(ctx context.Context, config *IndexConfig) (*PipelinedIndexClient, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", ShardConfig{Host: config.Host, Port: config.Port}.Addr())
+	if err != nil {
+		return nil, err
+	}
+	return NewPipelinedIndexClient(conn), nil
+}
+
+func (c *PipelinedIndexClient) IsOK() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err == nil
+}
+
+// Close closes the connection and fails all pending requests with ErrClientClosed.
+func (c *PipelinedIndexClient) Close(ctx context.Context) error {
+	c.fail(ErrClientClosed)
+	c.wg.Wait()
+	return nil
+}
+
+// fail puts the client into an error state, closes the connection and fails all pending requests.
+// Only the first error is kept.
+func (c *PipelinedIndexClient) fail(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	pending := c.pending
+	c.pending = nil
+	close(c.closing)
+	c.mu.Unlock()
+
+	c.conn.Close()
+	for _, call := range pending {
+		call.finish("", err)
+	}
+}
+
+func (c *PipelinedIndexClient) writeLoop() {
+	defer c.wg.Done()
+	writer := bufio.NewWriter(c.conn)
+	for {
+		var call *pipelinedCall
+		select {
+		case call = <-c.requests:
+		case <-c.closing:
+			return
+		}
+		// Write all requests that are already waiting before flushing.
+		for call != nil {
+			if !c.write(writer, call) {
+				return
+			}
+			select {
+			case call = <-c.requests:
+			default:
+				call = nil
+			}
+		}
+		err := writer.Flush()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
+
+func (c *PipelinedIndexClient) write(writer *bufio.Writer, call *pipelinedCall) bool {
+	err := call.ctx.Err()
+	if err != nil {
+		call.finish("", err)
+		return true
+	}
+	c.mu.Lock()
+	if c.err != nil {
+		err = c.err
+		c.mu.Unlock()
+		call.finish("", err)
+		return false
+	}
+	c.pending = append(c.pending, call)
+	c.updateDeadline()
+	c.mu.Unlock()
+	_, err = writer.WriteString(call.request + "\r\n")
+	if err != nil {
+		c.fail(err)
+		return false
+	}
+	return true
+}
+
+func (c *PipelinedIndexClient) readLoop() {
+	defer c.wg.Done()
+	reader := bufio.NewReader(c.conn)
+	for {
+		line, err := ReadLine(reader)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		c.mu.Lock()
+		if len(c.pending) == 0 {
+			c.mu.Unlock()
+			c.fail(fmt.Errorf("unexpected response: %v", line))
+			return
+		}
+		call := c.pending[0]
+		c.pending[0] = nil
+		c.pending = c.pending[1:]
+		c.updateDeadline()
+		c.mu.Unlock()
+
+		switch {
+		case strings.HasPrefix(line, kPrefixOK):
+			call.finish(strings.TrimPrefix(line, kPrefixOK), nil)
+		case strings.HasPrefix(line, kPrefixERR):
+			call.finish("", errors.New(strings.TrimPrefix(line, kPrefixERR)))
+		default:
+			err := fmt.Errorf("Invalid response: %v", line)
+			call.finish("", err)
+			c.fail(err)
+			return
+		}
+	}
+}
+
+// updateDeadline sets the connection deadline to the earliest deadline of the pending requests,
+// or clears it if none of them has a deadline. It must be called with c.mu held.
+func (c *PipelinedIndexClient) updateDeadline() {
+	var deadline time.Time
+	for _, call := range c.pending {
+		if d, ok := call.ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+	if !deadline.Equal(c.deadline) {
+		c.conn.SetDeadline(deadline)
+		c.deadline = deadline
+	}
+}
+
+func (c *PipelinedIndexClient) sendRequest(ctx context.Context, request string) (string, error) {
+	call := &pipelinedCall{ctx: ctx, request: request, done: make(chan struct{})}
+	select {
+	case c.requests <- call:
+	case <-c.closing:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return "", c.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	select {
+	case <-call.done:
+		return call.response, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (c *PipelinedIndexClient) Ping(ctx context.Context) error {
+	_, err := c.sendRequest(ctx, "echo")
+	return err
+}
+
+func (c *PipelinedIndexClient) GetAttribute(ctx context.Context, name string) (string, error) {
+	return c.sendRequest(ctx, fmt.Sprintf("get attribute %s", name))
+}
+
+func (c *PipelinedIndexClient) SetAttribute(ctx context.Context, name string, value string) error {
+	_, err := c.sendRequest(ctx, fmt.Sprintf("set attribute %s %s", name, value))
+	return err
+}
+
+func (c *PipelinedIndexClient) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	response, err := c.sendRequest(ctx, fmt.Sprintf("search %s", EncodeFingerprint(in.GetHashes(), false)))
+	if err != nil {
+		return nil, err
+	}
+	results, err := DecodeResults(response)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SearchResponse{Results: results}, nil
+}
+
+// PipelinedIndexClientPool spreads searches over a fixed number of shared PipelinedIndexClient connections.
+// Connections are opened on first use and reopened after they fail.
+type PipelinedIndexClientPool struct {
+	Config *IndexConfig
+
+	mu     sync.Mutex
+	slots  []*pipelinedSlot
+	next   int
+	closed bool
+}
+
+// pipelinedSlot holds one connection of the pool. Its lock is held while connecting,
+// so only searches waiting for the same connection are blocked by a slow dial.
+type pipelinedSlot struct {
+	mu     sync.Mutex
+	client *PipelinedIndexClient
+}
+
+func NewPipelinedIndexClientPool(config *IndexConfig, size int) *PipelinedIndexClientPool {
+	if size < 1 {
+		size = 1
+	}
+	slots := make([]*pipelinedSlot, size)
+	for i := range slots {
+		slots[i] = &pipelinedSlot{}
+	}
+	return &PipelinedIndexClientPool{Config: config, slots: slots}
+}
+
+func (p *PipelinedIndexClientPool) Close(ctx context.Context) {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	for _, slot := range p.slots {
+		slot.mu.Lock()
+		if slot.client != nil {
+			slot.client.Close(ctx)
+			slot.client = nil
+		}
+		slot.mu.Unlock()
+	}
+}
+
+func (p *PipelinedIndexClientPool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+func (p *PipelinedIndexClientPool) getClient(ctx context.Context) (*PipelinedIndexClient, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	slot := p.slots[p.next]
+	p.next = (p.next + 1) % len(p.slots)
+	p.mu.Unlock()
+
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if slot.client != nil && slot.client.IsOK() {
+		return slot.client, nil
+	}
+	if slot.client != nil {
+		slot.client.Close(ctx)
+		slot.client = nil
+	}
+	client, err := ConnectPipelined(ctx, p.Config)
+	if err != nil {
+		return nil, err
+	}
+	// The pool could have been closed while connecting.
+	if p.isClosed() {
+		client.Close(ctx)
+		return nil, ErrClientClosed
+	}
+	slot.client = client
+	return client, nil
+}
+
+func (p *PipelinedIndexClientPool) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.Search(ctx, in)
+}
@@ -0,0 +1,222 @@
+package index
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelinedIndexClient_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	server, cfg := startTestServer(t)
+	defer server.Close()
+	idx := server.Index.(*MemoryIndex)
+	for i := uint32(1); i <= 50; i++ {
+		_, err := idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: i, Hashes: []uint32{i << 8}}}})
+		require.NoError(t, err)
+	}
+
+	client, err := ConnectPipelined(ctx, cfg)
+	require.NoError(t, err)
+	defer client.Close(ctx)
+
+	var wg sync.WaitGroup
+	for i := uint32(1); i <= 50; i++ {
+		wg.Add(1)
+		go func(id uint32) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				response, err := client.Search(ctx, &pb.SearchRequest{Hashes: []uint32{id << 8}})
+				if assert.NoError(t, err) {
+					assert.Equal(t, []*pb.Result{{Id: id, Hits: 1}}, response.GetResults())
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.NoError(t, client.SetAttribute(ctx, "foo", "bar"))
+	value, err := client.GetAttribute(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", value)
+	assert.True(t, client.IsOK())
+}
+
+type pipeServer struct {
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func newPipelinedTestClient() (*PipelinedIndexClient, *pipeServer) {
+	serverConn, clientConn := net.Pipe()
+	return NewPipelinedIndexClient(clientConn), &pipeServer{reader: bufio.NewReader(serverConn), writer: bufio.NewWriter(serverConn)}
+}
+
+func (s *pipeServer) expect(t *testing.T, request string) {
+	line, err := ReadLine(s.reader)
+	require.NoError(t, err)
+	require.Equal(t, request, line)
+}
+
+func (s *pipeServer) respond(t *testing.T, response string) {
+	require.NoError(t, WriteLine(s.writer, response))
+}
+
+func TestPipelinedIndexClient_Cancel(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPipelinedTestClient()
+	defer client.Close(ctx)
+
+	// a request cancelled before it's written is not sent
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := client.GetAttribute(cancelledCtx, "a")
+	assert.Equal(t, context.Canceled, err)
+
+	// a request cancelled while waiting for the response returns immediately
+	ctx1, cancel1 := context.WithCancel(ctx)
+	result1 := make(chan error, 1)
+	go func() {
+		_, err := client.GetAttribute(ctx1, "b")
+		result1 <- err
+	}()
+	server.expect(t, "get attribute b")
+	cancel1()
+	assert.Equal(t, context.Canceled, <-result1)
+
+	// and its response is discarded, the following requests still get the right responses
+	result2 := make(chan string, 1)
+	go func() {
+		value, err := client.GetAttribute(ctx, "c")
+		assert.NoError(t, err)
+		result2 <- value
+	}()
+	server.expect(t, "get attribute c")
+	server.respond(t, "OK value-b")
+	server.respond(t, "OK value-c")
+	assert.Equal(t, "value-c", <-result2)
+
+	go func() {
+		server.expect(t, "echo")
+		server.respond(t, "OK ")
+	}()
+	assert.NoError(t, client.Ping(ctx))
+	assert.True(t, client.IsOK())
+}
+
+func TestPipelinedIndexClient_ErrorResponse(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPipelinedTestClient()
+	defer client.Close(ctx)
+
+	go func() {
+		server.expect(t, "get attribute a")
+		server.respond(t, "ERR failed")
+	}()
+	_, err := client.GetAttribute(ctx, "a")
+	assert.EqualError(t, err, "failed")
+	assert.True(t, client.IsOK())
+}
+
+func TestPipelinedIndexClient_ProtocolError(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPipelinedTestClient()
+	defer client.Close(ctx)
+
+	results := make(chan error, 2)
+	go func() {
+		_, err := client.GetAttribute(ctx, "a")
+		results <- err
+	}()
+	server.expect(t, "get attribute a")
+	go func() {
+		_, err := client.GetAttribute(ctx, "b")
+		results <- err
+	}()
+	server.expect(t, "get attribute b")
+	server.respond(t, "garbage")
+
+	// both the request that got the invalid response and the one queued behind it fail
+	assert.Error(t, <-results)
+	assert.Error(t, <-results)
+	assert.False(t, client.IsOK())
+
+	_, err := client.GetAttribute(ctx, "c")
+	assert.Error(t, err)
+}
+
+func TestPipelinedIndexClient_UnexpectedResponse(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPipelinedTestClient()
+	defer client.Close(ctx)
+
+	server.respond(t, "OK ")
+	require.Eventually(t, func() bool { return !client.IsOK() }, time.Second, time.Millisecond)
+}
+
+func TestPipelinedIndexClient_ServerNotResponding(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPipelinedTestClient()
+	defer client.Close(ctx)
+
+	results := make(chan error, 1)
+	go func() {
+		_, err := client.GetAttribute(ctx, "a")
+		results <- err
+	}()
+	server.expect(t, "get attribute a")
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	go func() {
+		server.expect(t, "get attribute b")
+	}()
+	_, err := client.GetAttribute(timeoutCtx, "b")
+	assert.Error(t, err)
+
+	// the connection is closed once the deadline passes without a response, failing the other pending requests
+	select {
+	case err := <-results:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("pending request was not failed")
+	}
+	assert.False(t, client.IsOK())
+}
+
+func TestPipelinedIndexClientPool(t *testing.T) {
+	ctx := context.Background()
+	server, cfg := startTestServer(t)
+	defer server.Close()
+	_, err := server.Index.(*MemoryIndex).Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 1, Hashes: []uint32{0x10}}}})
+	require.NoError(t, err)
+
+	cfg.PipelinedConnections = 2
+	pool := NewSearcherPool(cfg, 10)
+	require.IsType(t, &PipelinedIndexClientPool{}, pool)
+	defer pool.Close(ctx)
+
+	for i := 0; i < 4; i++ {
+		response, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+		require.NoError(t, err)
+		assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.GetResults())
+	}
+
+	// failed connections are reopened
+	pool.(*PipelinedIndexClientPool).slots[0].client.Close(ctx)
+	for i := 0; i < 4; i++ {
+		_, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+		require.NoError(t, err)
+	}
+
+	pool.Close(ctx)
+	_, err = pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+	assert.Equal(t, ErrClientClosed, err)
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	pb "github.com/acoustid/go-acoustid/proto/index"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ProxyConfig struct {
@@ -31,24 +34,88 @@ type Proxy struct {
 	Pool   *IndexClientPool
 }
 
-func (p *Proxy) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+func (p *Proxy) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
 	if p.Config.RequestTimeout > 0 {
-		ctxWithTimeout, cancel := context.WithTimeout(ctx, p.Config.RequestTimeout)
-		defer cancel()
-		ctx = ctxWithTimeout
+		return context.WithTimeout(ctx, p.Config.RequestTimeout)
 	}
+	return context.WithCancel(ctx)
+}
+
+func (p *Proxy) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	ctx, cancel := p.requestContext(ctx)
+	defer cancel()
 	return p.Pool.Search(ctx, in)
 }
 
 func (p *Proxy) Insert(ctx context.Context, in *pb.InsertRequest) (*pb.InsertResponse, error) {
-	if p.Config.RequestTimeout > 0 {
-		ctxWithTimeout, cancel := context.WithTimeout(ctx, p.Config.RequestTimeout)
-		defer cancel()
-		ctx = ctxWithTimeout
-	}
+	ctx, cancel := p.requestContext(ctx)
+	defer cancel()
 	return p.Pool.Insert(ctx, in)
 }
 
+func (p *Proxy) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	ctx, cancel := p.requestContext(ctx)
+	defer cancel()
+	return p.Pool.Delete(ctx, in)
+}
+
+// Ping checks that the proxy can talk to the index server.
+func (p *Proxy) Ping(ctx context.Context, in *pb.PingRequest) (*pb.PingResponse, error) {
+	ctx, cancel := p.requestContext(ctx)
+	defer cancel()
+	err := p.Pool.Ping(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PingResponse{}, nil
+}
+
+func (p *Proxy) GetAttribute(ctx context.Context, in *pb.GetAttributeRequest) (*pb.GetAttributeResponse, error) {
+	if !isValidAttributeName(in.GetName()) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid attribute name %q", in.GetName())
+	}
+	ctx, cancel := p.requestContext(ctx)
+	defer cancel()
+	value, err := p.Pool.GetAttribute(ctx, in.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetAttributeResponse{Value: value}, nil
+}
+
+func (p *Proxy) SetAttribute(ctx context.Context, in *pb.SetAttributeRequest) (*pb.SetAttributeResponse, error) {
+	if !isValidAttributeName(in.GetName()) || strings.ContainsAny(in.GetValue(), "\r\n") {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid attribute %q", in.GetName())
+	}
+	ctx, cancel := p.requestContext(ctx)
+	defer cancel()
+	err := p.Pool.SetAttribute(ctx, in.GetName(), in.GetValue())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SetAttributeResponse{}, nil
+}
+
+// GetStats returns the last fingerprint ID stored in the index and the state of the proxy's connection pool.
+func (p *Proxy) GetStats(ctx context.Context, in *pb.GetStatsRequest) (*pb.GetStatsResponse, error) {
+	ctx, cancel := p.requestContext(ctx)
+	defer cancel()
+	maxID, err := GetLastFingerprintID(ctx, p.Pool)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetStatsResponse{
+		MaxDocumentId:     maxID,
+		ActiveConnections: int32(p.Pool.NumActive()),
+		IdleConnections:   int32(p.Pool.NumIdle()),
+	}, nil
+}
+
+// isValidAttributeName checks that the name can be sent over the line protocol.
+func isValidAttributeName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \r\n")
+}
+
 func RunProxy(cfg *ProxyConfig) {
 	if cfg.Debug {
 		log.SetLevel(log.DebugLevel)
@@ -0,0 +1,75 @@
+package index
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestProxy(t *testing.T) {
+	ctx := context.Background()
+	server, indexConfig := startTestServer(t)
+	defer server.Close()
+
+	cfg := NewProxyConfig()
+	cfg.Index = indexConfig
+	pool := NewIndexClientPool(cfg.Index, 2)
+	defer pool.Close(ctx)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	pb.RegisterIndexServer(grpcServer, &Proxy{Config: cfg, Pool: pool})
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewIndexClient(conn)
+
+	_, err = client.Ping(ctx, &pb.PingRequest{})
+	require.NoError(t, err)
+
+	_, err = client.SetAttribute(ctx, &pb.SetAttributeRequest{Name: "foo", Value: "bar baz"})
+	require.NoError(t, err)
+	attr, err := client.GetAttribute(ctx, &pb.GetAttributeRequest{Name: "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, "bar baz", attr.GetValue())
+
+	_, err = client.GetAttribute(ctx, &pb.GetAttributeRequest{Name: "foo bar"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.SetAttribute(ctx, &pb.SetAttributeRequest{Name: "foo", Value: "bar\r\nbegin"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stats, err := client.GetStats(ctx, &pb.GetStatsRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), stats.GetMaxDocumentId())
+
+	_, err = client.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 123, Hashes: []uint32{0x10}}}})
+	require.NoError(t, err)
+
+	stats, err = client.GetStats(ctx, &pb.GetStatsRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(123), stats.GetMaxDocumentId())
+	assert.Equal(t, int32(0), stats.GetActiveConnections())
+	assert.True(t, stats.GetIdleConnections() > 0)
+
+	response, err := client.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 123, Hits: 1}}, response.GetResults())
+
+	_, err = client.Delete(ctx, &pb.DeleteRequest{Ids: []uint32{123}})
+	require.NoError(t, err)
+
+	response, err = client.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+	require.NoError(t, err)
+	assert.Empty(t, response.GetResults())
+}
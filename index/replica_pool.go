@@ -0,0 +1,294 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	replicaSearchErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "acoustid_index_replica_search_errors_total",
+		Help: "Number of failed searches in one index replica",
+	}, []string{"replica"})
+	replicaEjections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "acoustid_index_replica_ejections_total",
+		Help: "Number of times an index replica was temporarily excluded from searches after a failure",
+	}, []string{"replica"})
+	hedgedSearches = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "acoustid_index_hedged_searches_total",
+		Help: "Number of searches that were also sent to a second replica because the first one was slow",
+	})
+)
+
+const (
+	// minReplicaEjectionTime is how long a replica is skipped after its first failure,
+	// the time doubles with each subsequent failure, up to maxReplicaEjectionTime.
+	minReplicaEjectionTime = time.Second
+	maxReplicaEjectionTime = 30 * time.Second
+
+	// minHedgeSamples is the number of latency samples needed before hedged requests are sent.
+	minHedgeSamples = 100
+	// maxHedgeSamples is the number of recent latency samples used for computing the hedge delay.
+	maxHedgeSamples = 1000
+)
+
+var ErrNoReplicas = errors.New("no index replicas")
+
+type replica struct {
+	addr     string
+	searcher Searcher
+	inflight int32
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+}
+
+func (r *replica) isEjected(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return now.Before(r.ejectedUntil)
+}
+
+func (r *replica) markFailed(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ejectionTime := minReplicaEjectionTime << uint(r.failures)
+	if ejectionTime > maxReplicaEjectionTime || ejectionTime <= 0 {
+		ejectionTime = maxReplicaEjectionTime
+	} else {
+		r.failures++
+	}
+	r.ejectedUntil = now.Add(ejectionTime)
+	replicaEjections.WithLabelValues(r.addr).Inc()
+	log.Warnf("Ejecting index replica %v for %v", r.addr, ejectionTime)
+}
+
+func (r *replica) markOK() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = 0
+	r.ejectedUntil = time.Time{}
+}
+
+// ReplicaPool sends searches to one of several index servers with the same data.
+// A replica whose search fails is excluded from searches for a while and the search is retried
+// on another replica. Optionally, if a search takes longer than usual, a second request is sent
+// to another replica and the first response is used.
+type ReplicaPool struct {
+	// Selection is either SelectRoundRobin or SelectLeastLoaded.
+	Selection string
+	// HedgePercentile, if not 0, is the percentile of recent search latencies after which a hedged request is sent.
+	HedgePercentile float64
+
+	replicas  []*replica
+	next      uint32
+	latencies latencyTracker
+	now       func() time.Time
+}
+
+// NewReplicaPool creates a pool of up to limit connections for each index server in the config.
+func NewReplicaPool(config *IndexConfig, limit int) *ReplicaPool {
+	addrs := append([]string{ShardConfig{Host: config.Host, Port: config.Port}.Addr()}, config.Replicas...)
+	searchers := make([]Searcher, len(addrs))
+	for i, addr := range addrs {
+		replicaConfig := NewIndexConfig()
+		replicaConfig.Host, replicaConfig.Port, _ = ParseAddr(addr)
+		replicaConfig.PipelinedConnections = config.PipelinedConnections
+		searchers[i] = NewSearcherPool(replicaConfig, limit)
+	}
+	p := newReplicaPool(addrs, searchers)
+	p.Selection = config.ReplicaSelection
+	p.HedgePercentile = config.HedgePercentile
+	return p
+}
+
+func newReplicaPool(addrs []string, searchers []Searcher) *ReplicaPool {
+	p := &ReplicaPool{Selection: SelectRoundRobin, now: time.Now}
+	for i, addr := range addrs {
+		p.replicas = append(p.replicas, &replica{addr: addr, searcher: searchers[i]})
+	}
+	return p
+}
+
+// Close closes connection pools of all replicas.
+func (p *ReplicaPool) Close(ctx context.Context) {
+	for _, r := range p.replicas {
+		if pool, ok := r.searcher.(SearcherPool); ok {
+			pool.Close(ctx)
+		}
+	}
+}
+
+type replicaSearchResult struct {
+	replica  *replica
+	response *pb.SearchResponse
+	err      error
+}
+
+func (p *ReplicaPool) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan replicaSearchResult, len(p.replicas))
+	tried := make(map[*replica]bool, len(p.replicas))
+	pending := 0
+	start := func() bool {
+		r := p.pick(tried)
+		if r == nil {
+			return false
+		}
+		tried[r] = true
+		pending++
+		go func() {
+			response, err := p.searchReplica(ctx, r, in)
+			results <- replicaSearchResult{replica: r, response: response, err: err}
+		}()
+		return true
+	}
+
+	if !start() {
+		return nil, ErrNoReplicas
+	}
+
+	var hedge <-chan time.Time
+	if delay, ok := p.hedgeDelay(); ok && len(p.replicas) > 1 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				return result.response, nil
+			}
+			lastErr = result.err
+			if ctx.Err() != nil {
+				return nil, lastErr
+			}
+			if pending == 0 && start() {
+				log.Infof("Retrying search on another index replica after %v failed: %v", result.replica.addr, result.err)
+			}
+		case <-hedge:
+			hedge = nil
+			if start() {
+				hedgedSearches.Inc()
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+func (p *ReplicaPool) searchReplica(ctx context.Context, r *replica, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	atomic.AddInt32(&r.inflight, 1)
+	defer atomic.AddInt32(&r.inflight, -1)
+
+	started := p.now()
+	response, err := r.searcher.Search(ctx, in)
+	if err != nil {
+		// Searches cancelled because another replica responded first, or because the caller gave up, are not the replica's fault.
+		if ctx.Err() == nil {
+			replicaSearchErrors.WithLabelValues(r.addr).Inc()
+			r.markFailed(p.now())
+		}
+		return nil, err
+	}
+	r.markOK()
+	p.latencies.add(p.now().Sub(started))
+	return response, nil
+}
+
+// pick selects a replica that was not tried yet. Ejected replicas are only used if there is no other choice.
+func (p *ReplicaPool) pick(tried map[*replica]bool) *replica {
+	n := len(p.replicas)
+	if n == 0 {
+		return nil
+	}
+	now := p.now()
+	offset := int(atomic.AddUint32(&p.next, 1) - 1)
+	for _, allowEjected := range []bool{false, true} {
+		var best *replica
+		var bestLoad int32
+		for i := 0; i < n; i++ {
+			r := p.replicas[(offset+i)%n]
+			if tried[r] || (!allowEjected && r.isEjected(now)) {
+				continue
+			}
+			if p.Selection != SelectLeastLoaded {
+				return r
+			}
+			load := atomic.LoadInt32(&r.inflight)
+			if best == nil || load < bestLoad {
+				best = r
+				bestLoad = load
+			}
+		}
+		if best != nil {
+			return best
+		}
+	}
+	return nil
+}
+
+func (p *ReplicaPool) hedgeDelay() (time.Duration, bool) {
+	if p.HedgePercentile <= 0 {
+		return 0, false
+	}
+	return p.latencies.percentile(p.HedgePercentile)
+}
+
+// latencyTracker keeps recent latencies in a ring buffer and computes their percentiles.
+// Percentiles are cached and only recomputed after minHedgeSamples new latencies are added.
+type latencyTracker struct {
+	mu          sync.Mutex
+	samples     []time.Duration
+	pos         int
+	sinceUpdate int
+	sorted      []time.Duration
+}
+
+func (t *latencyTracker) add(latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.samples) < maxHedgeSamples {
+		t.samples = append(t.samples, latency)
+	} else {
+		t.samples[t.pos] = latency
+		t.pos = (t.pos + 1) % maxHedgeSamples
+	}
+	t.sinceUpdate++
+}
+
+func (t *latencyTracker) percentile(pct float64) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.samples) < minHedgeSamples {
+		return 0, false
+	}
+	if t.sorted == nil || t.sinceUpdate >= minHedgeSamples {
+		t.sorted = append(t.sorted[:0], t.samples...)
+		sort.Slice(t.sorted, func(i, j int) bool { return t.sorted[i] < t.sorted[j] })
+		t.sinceUpdate = 0
+	}
+	i := int(math.Ceil(pct/100*float64(len(t.sorted)))) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(t.sorted) {
+		i = len(t.sorted) - 1
+	}
+	return t.sorted[i], true
+}
@@ -0,0 +1,184 @@
+package index
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingSearcher struct {
+	Searcher
+	calls int32
+}
+
+func (s *countingSearcher) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	atomic.AddInt32(&s.calls, 1)
+	return s.Searcher.Search(ctx, in)
+}
+
+func newTestReplicaIndex(t *testing.T) *MemoryIndex {
+	idx := NewMemoryIndex()
+	_, err := idx.Insert(context.Background(), &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 1, Hashes: []uint32{0x10, 0x20}}}})
+	require.NoError(t, err)
+	return idx
+}
+
+func TestReplicaPool_RoundRobin(t *testing.T) {
+	ctx := context.Background()
+	idx := newTestReplicaIndex(t)
+	a := &countingSearcher{Searcher: idx}
+	b := &countingSearcher{Searcher: idx}
+	pool := newReplicaPool([]string{"a", "b"}, []Searcher{a, b})
+
+	for i := 0; i < 10; i++ {
+		response, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+		require.NoError(t, err)
+		assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.GetResults())
+	}
+	assert.Equal(t, int32(5), a.calls)
+	assert.Equal(t, int32(5), b.calls)
+}
+
+func TestReplicaPool_Failover(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	failing := &countingSearcher{Searcher: failingSearcher{}}
+	healthy := &countingSearcher{Searcher: newTestReplicaIndex(t)}
+	pool := newReplicaPool([]string{"a", "b"}, []Searcher{failing, healthy})
+	pool.now = func() time.Time { return now }
+
+	response, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.GetResults())
+	assert.Equal(t, int32(1), failing.calls)
+
+	// the failed replica is ejected
+	for i := 0; i < 4; i++ {
+		_, err = pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), failing.calls)
+	assert.Equal(t, int32(5), healthy.calls)
+
+	// and tried again after the ejection time, which grows with each failure
+	now = now.Add(minReplicaEjectionTime)
+	for i := 0; i < 2; i++ {
+		_, err = pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), failing.calls)
+	assert.Equal(t, now.Add(2*minReplicaEjectionTime), pool.replicas[0].ejectedUntil)
+}
+
+func TestReplicaPool_AllFailed(t *testing.T) {
+	ctx := context.Background()
+	pool := newReplicaPool([]string{"a", "b"}, []Searcher{failingSearcher{}, failingSearcher{}})
+
+	_, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+	assert.Error(t, err)
+
+	// ejected replicas are still used if there is nothing else
+	_, err = pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+	assert.EqualError(t, err, "failed")
+}
+
+func TestReplicaPool_CancelledIsNotFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	pool := newReplicaPool([]string{"a"}, []Searcher{slowSearcher{}})
+
+	_, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.False(t, pool.replicas[0].isEjected(time.Now()))
+}
+
+func TestReplicaPool_LeastLoaded(t *testing.T) {
+	ctx := context.Background()
+	idx := newTestReplicaIndex(t)
+	a := &countingSearcher{Searcher: idx}
+	b := &countingSearcher{Searcher: idx}
+	pool := newReplicaPool([]string{"a", "b"}, []Searcher{a, b})
+	pool.Selection = SelectLeastLoaded
+	atomic.StoreInt32(&pool.replicas[0].inflight, 3)
+
+	for i := 0; i < 4; i++ {
+		_, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(0), a.calls)
+	assert.Equal(t, int32(4), b.calls)
+}
+
+func TestReplicaPool_Hedge(t *testing.T) {
+	ctx := context.Background()
+	healthy := &countingSearcher{Searcher: newTestReplicaIndex(t)}
+	pool := newReplicaPool([]string{"a", "b"}, []Searcher{slowSearcher{}, healthy})
+	pool.HedgePercentile = 90
+	for i := 0; i < minHedgeSamples; i++ {
+		pool.latencies.add(time.Millisecond)
+	}
+
+	for i := 0; i < 4; i++ {
+		response, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+		require.NoError(t, err)
+		assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.GetResults())
+	}
+	assert.Equal(t, int32(4), healthy.calls)
+	assert.False(t, pool.replicas[0].isEjected(time.Now()))
+}
+
+func TestLatencyTracker(t *testing.T) {
+	var tracker latencyTracker
+	_, ok := tracker.percentile(50)
+	assert.False(t, ok)
+
+	for i := 1; i <= maxHedgeSamples+minHedgeSamples; i++ {
+		tracker.add(time.Duration(i) * time.Millisecond)
+	}
+	latency, ok := tracker.percentile(50)
+	require.True(t, ok)
+	assert.Equal(t, time.Duration(minHedgeSamples+maxHedgeSamples/2)*time.Millisecond, latency)
+	latency, ok = tracker.percentile(100)
+	require.True(t, ok)
+	assert.Equal(t, time.Duration(minHedgeSamples+maxHedgeSamples)*time.Millisecond, latency)
+}
+
+func TestReplicaPool_Servers(t *testing.T) {
+	ctx := context.Background()
+	server1, cfg1 := startTestServer(t)
+	defer server1.Close()
+	server2, cfg2 := startTestServer(t)
+	defer server2.Close()
+	for _, server := range []*Server{server1, server2} {
+		_, err := server.Index.(*MemoryIndex).Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 1, Hashes: []uint32{0x10}}}})
+		require.NoError(t, err)
+	}
+
+	cfg := NewIndexConfig()
+	cfg.Host = cfg1.Host
+	cfg.Port = cfg1.Port
+	cfg.Replicas = []string{net.JoinHostPort(cfg2.Host, strconv.Itoa(cfg2.Port))}
+	pool := NewReplicaPool(cfg, 2)
+	defer pool.Close(ctx)
+
+	for i := 0; i < 2; i++ {
+		response, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+		require.NoError(t, err)
+		assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.GetResults())
+	}
+
+	// one replica restarting doesn't make searches fail
+	server1.Close()
+	for i := 0; i < 4; i++ {
+		response, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x10}})
+		require.NoError(t, err)
+		assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.GetResults())
+	}
+}
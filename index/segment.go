@@ -0,0 +1,411 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sort"
+	"sync/atomic"
+
+	"github.com/acoustid/go-acoustid/util"
+	"github.com/acoustid/go-acoustid/util/intset"
+	"github.com/acoustid/go-acoustid/util/vfs"
+)
+
+// Segment files have the following layout:
+//
+//	magic
+//	postings   for each hash, the number of documents and delta-encoded document IDs
+//	directory  for each hash, delta-encoded hash and the size of its postings
+//	documents  SparseBitSet of all documents stored in the segment
+//	deleted    SparseBitSet of documents deleted in the segment
+//	footer     directory offset, documents offset, deleted documents offset, number of hashes,
+//	           CRC-32 of all previous data, magic
+//
+// All numbers in the postings and the directory are SQLite-style varints.
+// Fixed-size numbers in the footer are little-endian.
+
+var segmentMagic = []byte("AIDXSEG2")
+
+// segmentMagicV1 marks segments written before deleted documents were stored in the segment.
+// They are no longer supported and need to be rebuilt.
+var segmentMagicV1 = []byte("AIDXSEG1")
+
+const segmentFooterSize = 8 + 8 + 8 + 4 + 4 + 8
+
+var errCorruptSegment = errors.New("corrupt segment")
+
+var errUnsupportedSegmentVersion = errors.New("unsupported segment version AIDXSEG1, the index needs to be rebuilt")
+
+func segmentFileName(id uint64) string {
+	return fmt.Sprintf("segment-%08d.dat", id)
+}
+
+type segmentWriter struct {
+	file       vfs.AtomicOutputFile
+	buf        *bufio.Writer
+	crc        hash.Hash32
+	offset     int64
+	dir        bytes.Buffer
+	lastHash   uint32
+	numHashes  uint32
+	varint     [util.MaxVarintLen32]byte
+	docs       *intset.SparseBitSet
+	deleted    *intset.SparseBitSet
+	postingBuf []byte
+}
+
+func newSegmentWriter(fs vfs.FileSystem, name string) (*segmentWriter, error) {
+	file, err := fs.CreateAtomicFile(name)
+	if err != nil {
+		return nil, err
+	}
+	w := &segmentWriter{file: file, crc: crc32.NewIEEE(), docs: intset.NewSparseBitSet(0), deleted: intset.NewSparseBitSet(0)}
+	w.buf = bufio.NewWriter(io.MultiWriter(file, w.crc))
+	err = w.write(segmentMagic)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *segmentWriter) write(data []byte) error {
+	n, err := w.buf.Write(data)
+	w.offset += int64(n)
+	return err
+}
+
+func (w *segmentWriter) putUvarint(buf []byte, x uint32) []byte {
+	n := util.PutSQLiteUvarint32(w.varint[:], x)
+	return append(buf, w.varint[:n]...)
+}
+
+// add writes postings of one hash. Hashes must be added in ascending order, document IDs must be sorted and unique.
+func (w *segmentWriter) add(hash uint32, docIDs []uint32) error {
+	if w.numHashes > 0 && hash <= w.lastHash {
+		return fmt.Errorf("hash %v added out of order", hash)
+	}
+	posting := w.putUvarint(w.postingBuf[:0], uint32(len(docIDs)))
+	var lastID uint32
+	for _, id := range docIDs {
+		posting = w.putUvarint(posting, id-lastID)
+		lastID = id
+	}
+	w.postingBuf = posting
+	err := w.write(posting)
+	if err != nil {
+		return err
+	}
+	w.dir.Write(w.putUvarint(nil, hash-w.lastHash))
+	w.dir.Write(w.putUvarint(nil, uint32(len(posting))))
+	w.lastHash = hash
+	w.numHashes++
+	return nil
+}
+
+// addDoc marks the document as stored in the segment, even if it has no hashes.
+func (w *segmentWriter) addDoc(id uint32) {
+	w.docs.Add(id)
+}
+
+// deleteDoc records that the document was deleted, so that it's hidden in older segments.
+func (w *segmentWriter) deleteDoc(id uint32) {
+	w.deleted.Add(id)
+}
+
+func (w *segmentWriter) writeSet(set *intset.SparseBitSet) error {
+	var buf bytes.Buffer
+	err := set.Write(&buf)
+	if err != nil {
+		return err
+	}
+	return w.write(buf.Bytes())
+}
+
+// commit writes the directory, the document sets and the footer, and atomically makes the file visible.
+func (w *segmentWriter) commit() error {
+	dirOffset := w.offset
+	err := w.write(w.dir.Bytes())
+	if err != nil {
+		return err
+	}
+	docsOffset := w.offset
+	err = w.writeSet(w.docs)
+	if err != nil {
+		return err
+	}
+	deletedOffset := w.offset
+	err = w.writeSet(w.deleted)
+	if err != nil {
+		return err
+	}
+	footer := make([]byte, segmentFooterSize)
+	binary.LittleEndian.PutUint64(footer[0:], uint64(dirOffset))
+	binary.LittleEndian.PutUint64(footer[8:], uint64(docsOffset))
+	binary.LittleEndian.PutUint64(footer[16:], uint64(deletedOffset))
+	binary.LittleEndian.PutUint32(footer[24:], w.numHashes)
+	err = w.buf.Flush()
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(footer[28:], w.crc.Sum32())
+	copy(footer[32:], segmentMagic)
+	_, err = w.file.Write(footer)
+	if err != nil {
+		return err
+	}
+	err = w.file.Sync()
+	if err != nil {
+		return err
+	}
+	return w.file.Commit()
+}
+
+func (w *segmentWriter) close() error {
+	return w.file.Close()
+}
+
+// segment is an immutable part of a DiskIndex. It keeps the directory and the document sets in memory,
+// postings are read from the file on demand.
+type segment struct {
+	id      uint64
+	fs      vfs.FileSystem
+	file    vfs.InputFile
+	hashes  []uint32
+	offsets []int64
+	docs    *intset.SparseBitSet
+	deleted *intset.SparseBitSet
+	// numDocs is the number of stored and deleted documents.
+	numDocs int
+
+	refs     int32
+	obsolete int32
+}
+
+func openSegment(fs vfs.FileSystem, id uint64) (*segment, error) {
+	file, err := fs.OpenFile(segmentFileName(id))
+	if err != nil {
+		return nil, err
+	}
+	s := &segment{id: id, fs: fs, file: file, refs: 1}
+	err = s.load()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to load segment %v: %w", id, err)
+	}
+	return s, nil
+}
+
+func (s *segment) load() error {
+	size := s.file.Size()
+	if size >= int64(len(segmentMagicV1)) {
+		magic := make([]byte, len(segmentMagicV1))
+		_, err := s.file.ReadAt(magic, size-int64(len(magic)))
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(magic, segmentMagicV1) {
+			return errUnsupportedSegmentVersion
+		}
+	}
+	if size < int64(len(segmentMagic))+segmentFooterSize {
+		return errCorruptSegment
+	}
+	footer := make([]byte, segmentFooterSize)
+	_, err := s.file.ReadAt(footer, size-segmentFooterSize)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(footer[32:], segmentMagic) {
+		return errCorruptSegment
+	}
+	dirOffset := int64(binary.LittleEndian.Uint64(footer[0:]))
+	docsOffset := int64(binary.LittleEndian.Uint64(footer[8:]))
+	deletedOffset := int64(binary.LittleEndian.Uint64(footer[16:]))
+	numHashes := binary.LittleEndian.Uint32(footer[24:])
+	checksum := binary.LittleEndian.Uint32(footer[28:])
+	dataSize := size - segmentFooterSize
+	if dirOffset < int64(len(segmentMagic)) || dirOffset > docsOffset || docsOffset > deletedOffset || deletedOffset > dataSize {
+		return errCorruptSegment
+	}
+
+	crc := crc32.NewIEEE()
+	_, err = io.Copy(crc, io.NewSectionReader(s.file, 0, dataSize))
+	if err != nil {
+		return err
+	}
+	if crc.Sum32() != checksum {
+		return errCorruptSegment
+	}
+
+	dir := bufio.NewReader(io.NewSectionReader(s.file, dirOffset, docsOffset-dirOffset))
+	s.hashes = make([]uint32, numHashes)
+	s.offsets = make([]int64, numHashes+1)
+	s.offsets[0] = int64(len(segmentMagic))
+	var hash uint32
+	for i := range s.hashes {
+		delta, err := readSQLiteUvarint32(dir)
+		if err != nil {
+			return err
+		}
+		length, err := readSQLiteUvarint32(dir)
+		if err != nil {
+			return err
+		}
+		hash += delta
+		s.hashes[i] = hash
+		s.offsets[i+1] = s.offsets[i] + int64(length)
+	}
+	if s.offsets[numHashes] != dirOffset {
+		return errCorruptSegment
+	}
+
+	s.docs = intset.NewSparseBitSet(0)
+	err = s.docs.Read(io.NewSectionReader(s.file, docsOffset, deletedOffset-docsOffset))
+	if err != nil {
+		return err
+	}
+	s.deleted = intset.NewSparseBitSet(0)
+	err = s.deleted.Read(io.NewSectionReader(s.file, deletedOffset, dataSize-deletedOffset))
+	if err != nil {
+		return err
+	}
+	s.numDocs = s.docs.Len() + s.deleted.Len()
+	return nil
+}
+
+// postings returns IDs of documents containing the hash.
+func (s *segment) postings(hash uint32) ([]uint32, error) {
+	i := sort.Search(len(s.hashes), func(i int) bool { return s.hashes[i] >= hash })
+	if i == len(s.hashes) || s.hashes[i] != hash {
+		return nil, nil
+	}
+	block := make([]byte, s.offsets[i+1]-s.offsets[i])
+	_, err := s.file.ReadAt(block, s.offsets[i])
+	if err != nil {
+		return nil, err
+	}
+	return decodePostings(block)
+}
+
+// containsDoc returns true if the document is stored or deleted in the segment, in both cases
+// the segment hides the document in older segments.
+func (s *segment) containsDoc(id uint32) bool {
+	return s.docs.Contains(id) || s.deleted.Contains(id)
+}
+
+func (s *segment) acquire() {
+	atomic.AddInt32(&s.refs, 1)
+}
+
+// release drops one reference to the segment. When there are no more references, the file is closed
+// and if the segment is no longer part of the index, it's also removed.
+func (s *segment) release() error {
+	if atomic.AddInt32(&s.refs, -1) > 0 {
+		return nil
+	}
+	err := s.file.Close()
+	if atomic.LoadInt32(&s.obsolete) != 0 {
+		err = s.fs.Remove(segmentFileName(s.id))
+	}
+	return err
+}
+
+func (s *segment) markObsolete() {
+	atomic.StoreInt32(&s.obsolete, 1)
+}
+
+// segmentIterator reads postings of all hashes in the segment sequentially, in ascending hash order.
+type segmentIterator struct {
+	s      *segment
+	i      int
+	reader *bufio.Reader
+}
+
+func (s *segment) iterator() *segmentIterator {
+	dirOffset := s.offsets[len(s.offsets)-1]
+	start := int64(len(segmentMagic))
+	return &segmentIterator{s: s, reader: bufio.NewReader(io.NewSectionReader(s.file, start, dirOffset-start))}
+}
+
+func (it *segmentIterator) done() bool {
+	return it.i >= len(it.s.hashes)
+}
+
+func (it *segmentIterator) hash() uint32 {
+	return it.s.hashes[it.i]
+}
+
+// next returns postings of the current hash and moves to the next one.
+func (it *segmentIterator) next() ([]uint32, error) {
+	block := make([]byte, it.s.offsets[it.i+1]-it.s.offsets[it.i])
+	_, err := io.ReadFull(it.reader, block)
+	if err != nil {
+		return nil, err
+	}
+	it.i++
+	return decodePostings(block)
+}
+
+func decodePostings(block []byte) ([]uint32, error) {
+	count, n, err := decodeSQLiteUvarint32(block)
+	if err != nil {
+		return nil, err
+	}
+	block = block[n:]
+	ids := make([]uint32, count)
+	var id uint32
+	for i := range ids {
+		delta, n, err := decodeSQLiteUvarint32(block)
+		if err != nil {
+			return nil, err
+		}
+		block = block[n:]
+		id += delta
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func sqliteUvarint32Len(b byte) int {
+	switch {
+	case b <= 244:
+		return 1
+	case b <= 252:
+		return 2
+	case b == 253:
+		return 3
+	case b == 254:
+		return 4
+	}
+	return 5
+}
+
+// decodeSQLiteUvarint32 is util.SQLiteUvarint32 with bounds checking.
+func decodeSQLiteUvarint32(buf []byte) (uint32, int, error) {
+	if len(buf) == 0 || len(buf) < sqliteUvarint32Len(buf[0]) {
+		return 0, 0, errCorruptSegment
+	}
+	x, n := util.SQLiteUvarint32(buf)
+	return x, n, nil
+}
+
+func readSQLiteUvarint32(r *bufio.Reader) (uint32, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	buf, err := r.Peek(sqliteUvarint32Len(b[0]))
+	if err != nil {
+		return 0, err
+	}
+	x, n := util.SQLiteUvarint32(buf)
+	_, err = r.Discard(n)
+	return x, err
+}
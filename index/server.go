@@ -0,0 +1,367 @@
+package index
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/acoustid/go-acoustid/util/vfs"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// SearchableIndex is an index that can be served over the line protocol.
+type SearchableIndex interface {
+	Index
+	Searcher
+}
+
+var ErrServerClosed = errors.New("index server closed")
+
+var errNotInTx = errors.New("not in transaction")
+var errAlreadyInTx = errors.New("already in transaction")
+var errUnknownCommand = errors.New("unknown command")
+var errInvalidArguments = errors.New("invalid arguments")
+
+// Server implements the server side of the text protocol used by IndexClient.
+// Each connection can have one active transaction, which is rolled back if the client disconnects.
+type Server struct {
+	Index SearchableIndex
+
+	mu       sync.Mutex
+	closed   bool
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+func NewServer(idx SearchableIndex) *Server {
+	return &Server{Index: idx, conns: make(map[net.Conn]struct{})}
+}
+
+// Serve accepts connections on the listener until the server is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting new connections, closes the active ones and waits for their handlers to finish.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+type serverSession struct {
+	idx SearchableIndex
+	tx  Tx
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	ctx := context.Background()
+	session := &serverSession{idx: s.Index}
+	defer session.close(ctx)
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		request, err := ReadLine(reader)
+		if err != nil {
+			if err != io.EOF {
+				log.Debugf("Failed to read request from %v: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		var response string
+		result, err := session.handle(ctx, request)
+		if err != nil {
+			response = kPrefixERR + err.Error()
+		} else {
+			response = kPrefixOK + result
+		}
+		err = WriteLine(writer, response)
+		if err != nil {
+			log.Debugf("Failed to write response to %v: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+func (s *serverSession) close(ctx context.Context) {
+	if s.tx != nil {
+		err := s.tx.Rollback(ctx)
+		if err != nil {
+			log.Errorf("Failed to roll back transaction: %v", err)
+		}
+		s.tx = nil
+	}
+}
+
+func (s *serverSession) handle(ctx context.Context, request string) (string, error) {
+	command, args := splitCommand(request)
+	switch command {
+	case "echo":
+		return args, nil
+	case "begin":
+		if s.tx != nil {
+			return "", errAlreadyInTx
+		}
+		tx, err := s.idx.BeginTx(ctx)
+		if err != nil {
+			return "", err
+		}
+		s.tx = tx
+		return "", nil
+	case "commit":
+		if s.tx == nil {
+			return "", errNotInTx
+		}
+		tx := s.tx
+		s.tx = nil
+		return "", tx.Commit(ctx)
+	case "rollback":
+		if s.tx == nil {
+			return "", errNotInTx
+		}
+		tx := s.tx
+		s.tx = nil
+		return "", tx.Rollback(ctx)
+	case "insert":
+		if s.tx == nil {
+			return "", errNotInTx
+		}
+		idStr, hashesStr := splitCommand(args)
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			return "", errInvalidArguments
+		}
+		hashes, err := DecodeFingerprint(hashesStr)
+		if err != nil {
+			return "", errInvalidArguments
+		}
+		return "", s.tx.Insert(ctx, uint32(id), hashes)
+	case "delete":
+		if s.tx == nil {
+			return "", errNotInTx
+		}
+		id, err := strconv.ParseUint(args, 10, 32)
+		if err != nil {
+			return "", errInvalidArguments
+		}
+		return "", s.tx.Delete(ctx, uint32(id))
+	case "search":
+		if args == "" {
+			return "", nil
+		}
+		hashes, err := DecodeFingerprint(args)
+		if err != nil {
+			return "", errInvalidArguments
+		}
+		response, err := s.idx.Search(ctx, &pb.SearchRequest{Hashes: hashes})
+		if err != nil {
+			return "", err
+		}
+		return EncodeResults(response.GetResults()), nil
+	case "get":
+		kind, name := splitCommand(args)
+		if kind != "attribute" || name == "" || strings.Contains(name, " ") {
+			return "", errInvalidArguments
+		}
+		return s.idx.GetAttribute(ctx, name)
+	case "set":
+		kind, rest := splitCommand(args)
+		name, value := splitCommand(rest)
+		if kind != "attribute" || name == "" {
+			return "", errInvalidArguments
+		}
+		return "", s.idx.SetAttribute(ctx, name, value)
+	}
+	return "", errUnknownCommand
+}
+
+func splitCommand(str string) (string, string) {
+	parts := strings.SplitN(str, " ", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// EncodeResults encodes search results in the format expected by DecodeResults.
+func EncodeResults(results []*pb.Result) string {
+	var b strings.Builder
+	for i, result := range results {
+		if i > 0 {
+			b.WriteRune(' ')
+		}
+		b.WriteString(strconv.FormatUint(uint64(result.GetId()), 10))
+		b.WriteRune(':')
+		b.WriteString(strconv.FormatUint(uint64(result.GetHits()), 10))
+	}
+	return b.String()
+}
+
+type ServerConfig struct {
+	ListenHost string
+	ListenPort int
+	// DataDir is the directory where the index is stored. If empty, the index is only kept in memory.
+	DataDir string
+	Debug   bool
+}
+
+func NewServerConfig() *ServerConfig {
+	return &ServerConfig{
+		ListenHost: "localhost",
+		ListenPort: 6080,
+	}
+}
+
+func openServerIndex(cfg *ServerConfig) (SearchableIndex, error) {
+	if cfg.DataDir == "" {
+		log.Infof("Using in-memory index")
+		return NewMemoryIndex(), nil
+	}
+	fs, err := vfs.OpenDir(cfg.DataDir, true)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Using index stored in %v", fs.Path())
+	return OpenDiskIndex(fs)
+}
+
+func RunServer(cfg *ServerConfig) {
+	if cfg.Debug {
+		log.SetLevel(log.DebugLevel)
+	} else {
+		log.SetLevel(log.InfoLevel)
+	}
+
+	idx, err := openServerIndex(cfg)
+	if err != nil {
+		log.Fatalf("failed to open index: %v", err)
+	}
+
+	addr := net.JoinHostPort(cfg.ListenHost, strconv.Itoa(cfg.ListenPort))
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	server := NewServer(idx)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+		log.Infof("Shutting down")
+		server.Close()
+	}()
+
+	log.Infof("Listening on %v", addr)
+	err = server.Serve(lis)
+	if err != nil && err != ErrServerClosed {
+		log.Fatalf("failed to serve: %v", err)
+	}
+
+	// Serve returns as soon as the listener is closed, wait until all connections are finished before closing the index.
+	<-closed
+
+	err = idx.Close(context.Background())
+	if err != nil {
+		log.Fatalf("failed to close index: %v", err)
+	}
+}
+
+func RunServerCommand(c *cli.Context) error {
+	cfg := NewServerConfig()
+
+	cfg.Debug = c.Bool("debug")
+
+	cfg.ListenHost = c.String("listen-host")
+	cfg.ListenPort = c.Int("listen-port")
+	cfg.DataDir = c.String("data-dir")
+
+	RunServer(cfg)
+	return nil
+}
+
+var ServeCommand = cli.Command{
+	Name:  "serve",
+	Usage: "Runs index server",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:   "listen-host",
+			Usage:  "listen address",
+			Value:  "localhost",
+			EnvVar: "AINDEX_SERVER_LISTEN_HOST",
+		},
+		cli.IntFlag{
+			Name:   "listen-port",
+			Usage:  "listen port number",
+			Value:  6080,
+			EnvVar: "AINDEX_SERVER_LISTEN_PORT",
+		},
+		cli.StringFlag{
+			Name:   "data-dir",
+			Usage:  "directory where the index is stored, if not set, the index is only kept in memory",
+			EnvVar: "AINDEX_SERVER_DATA_DIR",
+		},
+	},
+	Action: RunServerCommand,
+}
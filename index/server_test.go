@@ -0,0 +1,126 @@
+package index
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestServer(t *testing.T) (*Server, *IndexConfig) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := NewServer(NewMemoryIndex())
+	go server.Serve(lis)
+	addr := lis.Addr().(*net.TCPAddr)
+	return server, &IndexConfig{Host: addr.IP.String(), Port: addr.Port}
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	server, cfg := startTestServer(t)
+	defer server.Close()
+
+	client, err := ConnectWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	defer client.Close(ctx)
+
+	require.NoError(t, client.Ping(ctx))
+
+	lastID, err := GetLastFingerprintID(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), lastID)
+
+	_, err = client.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{
+		{Id: 1, Hashes: []uint32{0x100, 0x200, 0x300}},
+		{Id: 2, Hashes: []uint32{0x200, 0x300, 0x400}},
+	}})
+	require.NoError(t, err)
+
+	lastID, err = GetLastFingerprintID(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), lastID)
+
+	response, err := client.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x300, 0x400}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 2, Hits: 2}, {Id: 1, Hits: 1}}, response.Results)
+
+	response, err = client.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x500}})
+	require.NoError(t, err)
+	assert.Empty(t, response.Results)
+
+	require.NoError(t, client.SetAttribute(ctx, "foo", "bar baz"))
+	value, err := client.GetAttribute(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "bar baz", value)
+
+	tx, err := client.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Insert(ctx, 3, []uint32{0x500}))
+	require.NoError(t, tx.Rollback(ctx))
+
+	response, err = client.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x500}})
+	require.NoError(t, err)
+	assert.Empty(t, response.Results)
+
+	_, err = client.Delete(ctx, &pb.DeleteRequest{Ids: []uint32{2}})
+	require.NoError(t, err)
+
+	response, err = client.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x300, 0x400}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.Results)
+	assert.True(t, client.IsOK())
+}
+
+func TestServer_Errors(t *testing.T) {
+	server, cfg := startTestServer(t)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	require.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	request := func(line string) string {
+		require.NoError(t, WriteLine(writer, line))
+		response, err := ReadLine(reader)
+		require.NoError(t, err)
+		return response
+	}
+
+	assert.Equal(t, "OK hello", request("echo hello"))
+	assert.Equal(t, "ERR not in transaction", request("insert 1 1,2,3"))
+	assert.Equal(t, "ERR not in transaction", request("commit"))
+	assert.Equal(t, "OK ", request("begin"))
+	assert.Equal(t, "ERR already in transaction", request("begin"))
+	assert.Equal(t, "ERR invalid arguments", request("insert x 1,2,3"))
+	assert.Equal(t, "OK ", request("insert 1 16,-32"))
+	assert.Equal(t, "OK ", request("commit"))
+	assert.Equal(t, "OK 1:2", request("search 16,-32,48"))
+	assert.Equal(t, "ERR unknown command", request("foo"))
+	assert.Equal(t, "ERR invalid arguments", request("get foo"))
+}
+
+func TestServer_Pool(t *testing.T) {
+	ctx := context.Background()
+	server, cfg := startTestServer(t)
+	defer server.Close()
+
+	pool := NewIndexClientPool(cfg, 2)
+	defer pool.Close(ctx)
+
+	_, err := pool.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 1, Hashes: []uint32{0x100}}}})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		response, err := pool.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100}})
+		require.NoError(t, err)
+		assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.Results)
+	}
+}
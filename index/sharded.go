@@ -0,0 +1,165 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	shardSearchErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "acoustid_index_shard_search_errors_total",
+		Help: "Number of failed or timed out searches in one index shard",
+	}, []string{"shard"})
+	partialSearches = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "acoustid_index_partial_searches_total",
+		Help: "Number of sharded searches that returned results from only some of the shards",
+	})
+)
+
+// defaultShardTimeoutFraction is the part of the remaining request time given to each shard when Timeout is not set,
+// so that a slow shard doesn't take the whole request deadline and results from the other shards can still be used.
+const defaultShardTimeoutFraction = 0.8
+
+type Searcher interface {
+	Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error)
+}
+
+type Shard struct {
+	Config   ShardConfig
+	Searcher Searcher
+}
+
+// ShardedSearcher sends searches to all shards of an index and merges their results.
+// If some shards fail or don't respond within Timeout, results from the other shards are returned.
+type ShardedSearcher struct {
+	Shards       []Shard
+	Partitioning string
+	// Timeout limits how long to wait for each shard. If it's 0, each shard gets a fraction of the time
+	// remaining until the request context deadline, or is waited for until the context is done if it has no deadline.
+	Timeout time.Duration
+}
+
+// NewShardedSearcher creates a searcher with a pool of up to poolSize connections for each shard in the config.
+// Shards with replicas use a ReplicaPool.
+func NewShardedSearcher(config *IndexConfig, poolSize int) *ShardedSearcher {
+	s := &ShardedSearcher{Partitioning: config.Partitioning}
+	for _, shardConfig := range config.Shards {
+		poolConfig := NewIndexConfig()
+		poolConfig.Host = shardConfig.Host
+		poolConfig.Port = shardConfig.Port
+		poolConfig.PipelinedConnections = config.PipelinedConnections
+		var searcher Searcher
+		if len(shardConfig.Replicas) > 0 {
+			poolConfig.Replicas = shardConfig.Replicas
+			poolConfig.ReplicaSelection = config.ReplicaSelection
+			poolConfig.HedgePercentile = config.HedgePercentile
+			searcher = NewReplicaPool(poolConfig, poolSize)
+		} else {
+			searcher = NewSearcherPool(poolConfig, poolSize)
+		}
+		s.Shards = append(s.Shards, Shard{Config: shardConfig, Searcher: searcher})
+	}
+	return s
+}
+
+// ShardForHash returns the shard which stores the hash, when partitioning by hash.
+func ShardForHash(hash uint32, numShards int) int {
+	return int((hash >> (32 - QueryHashBits)) % uint32(numShards))
+}
+
+type shardSearchResult struct {
+	shard    int
+	response *pb.SearchResponse
+	err      error
+}
+
+func (s *ShardedSearcher) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	requests := s.splitRequest(in)
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Duration(float64(time.Until(deadline)) * defaultShardTimeoutFraction)
+		}
+	}
+
+	results := make(chan shardSearchResult, len(requests))
+	for i, request := range requests {
+		if request == nil {
+			results <- shardSearchResult{shard: i, response: &pb.SearchResponse{}}
+			continue
+		}
+		go func(i int, request *pb.SearchRequest) {
+			shardCtx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				shardCtx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			response, err := s.Shards[i].Searcher.Search(shardCtx, request)
+			results <- shardSearchResult{shard: i, response: response, err: err}
+		}(i, request)
+	}
+
+	hits := make(map[uint32]uint32)
+	var firstErr error
+	numFailed := 0
+	for range requests {
+		result := <-results
+		shard := s.Shards[result.shard]
+		if result.err != nil {
+			log.Warnf("Search in index shard %v failed: %v", shard.Config.Addr(), result.err)
+			shardSearchErrors.WithLabelValues(shard.Config.Addr()).Inc()
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			numFailed++
+			continue
+		}
+		for _, r := range result.response.GetResults() {
+			if s.Partitioning != PartitionByHash && !shard.Config.ContainsID(r.GetId()) {
+				continue
+			}
+			hits[r.GetId()] += r.GetHits()
+		}
+	}
+
+	if numFailed > 0 {
+		if numFailed == len(requests) {
+			return nil, fmt.Errorf("search failed in all index shards: %w", firstErr)
+		}
+		partialSearches.Inc()
+	}
+
+	out := &pb.SearchResponse{Results: make([]*pb.Result, 0, len(hits))}
+	for id, count := range hits {
+		out.Results = append(out.Results, &pb.Result{Id: id, Hits: count})
+	}
+	sortResults(out.Results)
+	return out, nil
+}
+
+// splitRequest creates a request for each shard, nil if the shard doesn't need to be searched.
+func (s *ShardedSearcher) splitRequest(in *pb.SearchRequest) []*pb.SearchRequest {
+	requests := make([]*pb.SearchRequest, len(s.Shards))
+	if s.Partitioning != PartitionByHash {
+		for i := range requests {
+			requests[i] = in
+		}
+		return requests
+	}
+	for _, hash := range in.GetHashes() {
+		i := ShardForHash(hash&QueryHashMask, len(s.Shards))
+		if requests[i] == nil {
+			requests[i] = &pb.SearchRequest{}
+		}
+		requests[i].Hashes = append(requests[i].Hashes, hash)
+	}
+	return requests
+}
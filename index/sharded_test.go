@@ -0,0 +1,150 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type slowSearcher struct{}
+
+func (slowSearcher) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type failingSearcher struct{}
+
+func (failingSearcher) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	return nil, errors.New("failed")
+}
+
+func TestParseShardConfig(t *testing.T) {
+	cfg, err := ParseShardConfig("index1:6080")
+	require.NoError(t, err)
+	assert.Equal(t, ShardConfig{Host: "index1", Port: 6080}, cfg)
+
+	cfg, err = ParseShardConfig("index2:6080=1000-")
+	require.NoError(t, err)
+	assert.Equal(t, ShardConfig{Host: "index2", Port: 6080, MinID: 1000}, cfg)
+	assert.False(t, cfg.ContainsID(999))
+	assert.True(t, cfg.ContainsID(1000))
+
+	cfg, err = ParseShardConfig("index1:6080=-999")
+	require.NoError(t, err)
+	assert.Equal(t, ShardConfig{Host: "index1", Port: 6080, MaxID: 999}, cfg)
+
+	cfg, err = ParseShardConfig("index1:6080,index2:6080=1-")
+	require.NoError(t, err)
+	assert.Equal(t, ShardConfig{Host: "index1", Port: 6080, Replicas: []string{"index2:6080"}, MinID: 1}, cfg)
+
+	_, err = ParseShardConfig("index1")
+	assert.Error(t, err)
+	_, err = ParseShardConfig("index1:6080=x")
+	assert.Error(t, err)
+}
+
+func TestShardedSearcher_PartitionByID(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1234))
+	fingerprints := generateTestFingerprints(rng, 1, 100)
+
+	all := NewMemoryIndex()
+	_, err := all.Insert(ctx, &pb.InsertRequest{Fingerprints: fingerprints})
+	require.NoError(t, err)
+
+	searcher := &ShardedSearcher{Partitioning: PartitionByID}
+	for _, cfg := range []ShardConfig{{MaxID: 30}, {MinID: 31, MaxID: 60}, {MinID: 61}} {
+		idx := NewMemoryIndex()
+		for _, fp := range fingerprints {
+			if cfg.ContainsID(fp.Id) {
+				_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{fp}})
+				require.NoError(t, err)
+			}
+		}
+		searcher.Shards = append(searcher.Shards, Shard{Config: cfg, Searcher: idx})
+	}
+
+	assertSameSearchResults(t, rng, all, searcher)
+}
+
+func TestShardedSearcher_PartitionByHash(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1234))
+	fingerprints := generateTestFingerprints(rng, 1, 100)
+
+	all := NewMemoryIndex()
+	_, err := all.Insert(ctx, &pb.InsertRequest{Fingerprints: fingerprints})
+	require.NoError(t, err)
+
+	const numShards = 3
+	searcher := &ShardedSearcher{Partitioning: PartitionByHash}
+	for i := 0; i < numShards; i++ {
+		idx := NewMemoryIndex()
+		for _, fp := range fingerprints {
+			var hashes []uint32
+			for _, hash := range fp.Hashes {
+				if ShardForHash(hash, numShards) == i {
+					hashes = append(hashes, hash)
+				}
+			}
+			_, err = idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: fp.Id, Hashes: hashes}}})
+			require.NoError(t, err)
+		}
+		searcher.Shards = append(searcher.Shards, Shard{Searcher: idx})
+	}
+
+	assertSameSearchResults(t, rng, all, searcher)
+}
+
+func TestShardedSearcher_Failures(t *testing.T) {
+	ctx := context.Background()
+	idx := NewMemoryIndex()
+	_, err := idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 1, Hashes: []uint32{0x100}}}})
+	require.NoError(t, err)
+
+	searcher := &ShardedSearcher{
+		Partitioning: PartitionByID,
+		Timeout:      10 * time.Millisecond,
+		Shards: []Shard{
+			{Config: ShardConfig{Host: "a"}, Searcher: idx},
+			{Config: ShardConfig{Host: "b"}, Searcher: slowSearcher{}},
+			{Config: ShardConfig{Host: "c"}, Searcher: failingSearcher{}},
+		},
+	}
+	response, err := searcher.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.Results)
+
+	searcher.Shards = searcher.Shards[1:]
+	_, err = searcher.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100}})
+	assert.Error(t, err)
+}
+
+func TestShardedSearcher_DefaultTimeout(t *testing.T) {
+	idx := NewMemoryIndex()
+	_, err := idx.Insert(context.Background(), &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{{Id: 1, Hashes: []uint32{0x100}}}})
+	require.NoError(t, err)
+
+	searcher := &ShardedSearcher{
+		Partitioning: PartitionByID,
+		Shards: []Shard{
+			{Config: ShardConfig{Host: "a"}, Searcher: idx},
+			{Config: ShardConfig{Host: "b"}, Searcher: slowSearcher{}},
+		},
+	}
+
+	// without Timeout, the slow shard is given up on before the request deadline, so the other results are returned
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	response, err := searcher.Search(ctx, &pb.SearchRequest{Hashes: []uint32{0x100}})
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Result{{Id: 1, Hits: 1}}, response.Results)
+	assert.NoError(t, ctx.Err())
+}
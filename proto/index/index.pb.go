@@ -172,6 +172,76 @@ func (m *InsertResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_InsertResponse proto.InternalMessageInfo
 
+type DeleteRequest struct {
+	Ids                  []uint32 `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteRequest) Reset()         { *m = DeleteRequest{} }
+func (m *DeleteRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteRequest) ProtoMessage()    {}
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_91013751fa82c1bb, []int{4}
+}
+
+func (m *DeleteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteRequest.Unmarshal(m, b)
+}
+func (m *DeleteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteRequest.Merge(m, src)
+}
+func (m *DeleteRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteRequest.Size(m)
+}
+func (m *DeleteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteRequest proto.InternalMessageInfo
+
+func (m *DeleteRequest) GetIds() []uint32 {
+	if m != nil {
+		return m.Ids
+	}
+	return nil
+}
+
+type DeleteResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteResponse) Reset()         { *m = DeleteResponse{} }
+func (m *DeleteResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteResponse) ProtoMessage()    {}
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_91013751fa82c1bb, []int{5}
+}
+
+func (m *DeleteResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteResponse.Unmarshal(m, b)
+}
+func (m *DeleteResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteResponse.Marshal(b, m, deterministic)
+}
+func (m *DeleteResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteResponse.Merge(m, src)
+}
+func (m *DeleteResponse) XXX_Size() int {
+	return xxx_messageInfo_DeleteResponse.Size(m)
+}
+func (m *DeleteResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteResponse proto.InternalMessageInfo
+
 type Result struct {
 	Id                   uint32   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	Hits                 uint32   `protobuf:"varint,2,opt,name=hits,proto3" json:"hits,omitempty"`
@@ -184,7 +254,7 @@ func (m *Result) Reset()         { *m = Result{} }
 func (m *Result) String() string { return proto.CompactTextString(m) }
 func (*Result) ProtoMessage()    {}
 func (*Result) Descriptor() ([]byte, []int) {
-	return fileDescriptor_91013751fa82c1bb, []int{4}
+	return fileDescriptor_91013751fa82c1bb, []int{6}
 }
 
 func (m *Result) XXX_Unmarshal(b []byte) error {
@@ -231,7 +301,7 @@ func (m *Fingerprint) Reset()         { *m = Fingerprint{} }
 func (m *Fingerprint) String() string { return proto.CompactTextString(m) }
 func (*Fingerprint) ProtoMessage()    {}
 func (*Fingerprint) Descriptor() ([]byte, []int) {
-	return fileDescriptor_91013751fa82c1bb, []int{5}
+	return fileDescriptor_91013751fa82c1bb, []int{7}
 }
 
 func (m *Fingerprint) XXX_Unmarshal(b []byte) error {
@@ -266,35 +336,365 @@ func (m *Fingerprint) GetHashes() []uint32 {
 	return nil
 }
 
+type PingRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PingRequest) Reset()         { *m = PingRequest{} }
+func (m *PingRequest) String() string { return proto.CompactTextString(m) }
+func (*PingRequest) ProtoMessage()    {}
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_91013751fa82c1bb, []int{8}
+}
+
+func (m *PingRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PingRequest.Unmarshal(m, b)
+}
+func (m *PingRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PingRequest.Marshal(b, m, deterministic)
+}
+func (m *PingRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PingRequest.Merge(m, src)
+}
+func (m *PingRequest) XXX_Size() int {
+	return xxx_messageInfo_PingRequest.Size(m)
+}
+func (m *PingRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PingRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PingRequest proto.InternalMessageInfo
+
+type PingResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PingResponse) Reset()         { *m = PingResponse{} }
+func (m *PingResponse) String() string { return proto.CompactTextString(m) }
+func (*PingResponse) ProtoMessage()    {}
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_91013751fa82c1bb, []int{9}
+}
+
+func (m *PingResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PingResponse.Unmarshal(m, b)
+}
+func (m *PingResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PingResponse.Marshal(b, m, deterministic)
+}
+func (m *PingResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PingResponse.Merge(m, src)
+}
+func (m *PingResponse) XXX_Size() int {
+	return xxx_messageInfo_PingResponse.Size(m)
+}
+func (m *PingResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_PingResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PingResponse proto.InternalMessageInfo
+
+type GetAttributeRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetAttributeRequest) Reset()         { *m = GetAttributeRequest{} }
+func (m *GetAttributeRequest) String() string { return proto.CompactTextString(m) }
+func (*GetAttributeRequest) ProtoMessage()    {}
+func (*GetAttributeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_91013751fa82c1bb, []int{10}
+}
+
+func (m *GetAttributeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetAttributeRequest.Unmarshal(m, b)
+}
+func (m *GetAttributeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetAttributeRequest.Marshal(b, m, deterministic)
+}
+func (m *GetAttributeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetAttributeRequest.Merge(m, src)
+}
+func (m *GetAttributeRequest) XXX_Size() int {
+	return xxx_messageInfo_GetAttributeRequest.Size(m)
+}
+func (m *GetAttributeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetAttributeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetAttributeRequest proto.InternalMessageInfo
+
+func (m *GetAttributeRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type GetAttributeResponse struct {
+	Value                string   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetAttributeResponse) Reset()         { *m = GetAttributeResponse{} }
+func (m *GetAttributeResponse) String() string { return proto.CompactTextString(m) }
+func (*GetAttributeResponse) ProtoMessage()    {}
+func (*GetAttributeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_91013751fa82c1bb, []int{11}
+}
+
+func (m *GetAttributeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetAttributeResponse.Unmarshal(m, b)
+}
+func (m *GetAttributeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetAttributeResponse.Marshal(b, m, deterministic)
+}
+func (m *GetAttributeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetAttributeResponse.Merge(m, src)
+}
+func (m *GetAttributeResponse) XXX_Size() int {
+	return xxx_messageInfo_GetAttributeResponse.Size(m)
+}
+func (m *GetAttributeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetAttributeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetAttributeResponse proto.InternalMessageInfo
+
+func (m *GetAttributeResponse) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type SetAttributeRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value                string   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetAttributeRequest) Reset()         { *m = SetAttributeRequest{} }
+func (m *SetAttributeRequest) String() string { return proto.CompactTextString(m) }
+func (*SetAttributeRequest) ProtoMessage()    {}
+func (*SetAttributeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_91013751fa82c1bb, []int{12}
+}
+
+func (m *SetAttributeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetAttributeRequest.Unmarshal(m, b)
+}
+func (m *SetAttributeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetAttributeRequest.Marshal(b, m, deterministic)
+}
+func (m *SetAttributeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetAttributeRequest.Merge(m, src)
+}
+func (m *SetAttributeRequest) XXX_Size() int {
+	return xxx_messageInfo_SetAttributeRequest.Size(m)
+}
+func (m *SetAttributeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetAttributeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetAttributeRequest proto.InternalMessageInfo
+
+func (m *SetAttributeRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *SetAttributeRequest) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type SetAttributeResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetAttributeResponse) Reset()         { *m = SetAttributeResponse{} }
+func (m *SetAttributeResponse) String() string { return proto.CompactTextString(m) }
+func (*SetAttributeResponse) ProtoMessage()    {}
+func (*SetAttributeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_91013751fa82c1bb, []int{13}
+}
+
+func (m *SetAttributeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetAttributeResponse.Unmarshal(m, b)
+}
+func (m *SetAttributeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetAttributeResponse.Marshal(b, m, deterministic)
+}
+func (m *SetAttributeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetAttributeResponse.Merge(m, src)
+}
+func (m *SetAttributeResponse) XXX_Size() int {
+	return xxx_messageInfo_SetAttributeResponse.Size(m)
+}
+func (m *SetAttributeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetAttributeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetAttributeResponse proto.InternalMessageInfo
+
+type GetStatsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetStatsRequest) Reset()         { *m = GetStatsRequest{} }
+func (m *GetStatsRequest) String() string { return proto.CompactTextString(m) }
+func (*GetStatsRequest) ProtoMessage()    {}
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_91013751fa82c1bb, []int{14}
+}
+
+func (m *GetStatsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetStatsRequest.Unmarshal(m, b)
+}
+func (m *GetStatsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetStatsRequest.Marshal(b, m, deterministic)
+}
+func (m *GetStatsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetStatsRequest.Merge(m, src)
+}
+func (m *GetStatsRequest) XXX_Size() int {
+	return xxx_messageInfo_GetStatsRequest.Size(m)
+}
+func (m *GetStatsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetStatsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetStatsRequest proto.InternalMessageInfo
+
+type GetStatsResponse struct {
+	MaxDocumentId        uint32   `protobuf:"varint,1,opt,name=max_document_id,json=maxDocumentId,proto3" json:"max_document_id,omitempty"`
+	ActiveConnections    int32    `protobuf:"varint,2,opt,name=active_connections,json=activeConnections,proto3" json:"active_connections,omitempty"`
+	IdleConnections      int32    `protobuf:"varint,3,opt,name=idle_connections,json=idleConnections,proto3" json:"idle_connections,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetStatsResponse) Reset()         { *m = GetStatsResponse{} }
+func (m *GetStatsResponse) String() string { return proto.CompactTextString(m) }
+func (*GetStatsResponse) ProtoMessage()    {}
+func (*GetStatsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_91013751fa82c1bb, []int{15}
+}
+
+func (m *GetStatsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetStatsResponse.Unmarshal(m, b)
+}
+func (m *GetStatsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetStatsResponse.Marshal(b, m, deterministic)
+}
+func (m *GetStatsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetStatsResponse.Merge(m, src)
+}
+func (m *GetStatsResponse) XXX_Size() int {
+	return xxx_messageInfo_GetStatsResponse.Size(m)
+}
+func (m *GetStatsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetStatsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetStatsResponse proto.InternalMessageInfo
+
+func (m *GetStatsResponse) GetMaxDocumentId() uint32 {
+	if m != nil {
+		return m.MaxDocumentId
+	}
+	return 0
+}
+
+func (m *GetStatsResponse) GetActiveConnections() int32 {
+	if m != nil {
+		return m.ActiveConnections
+	}
+	return 0
+}
+
+func (m *GetStatsResponse) GetIdleConnections() int32 {
+	if m != nil {
+		return m.IdleConnections
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*SearchRequest)(nil), "index.SearchRequest")
 	proto.RegisterType((*SearchResponse)(nil), "index.SearchResponse")
 	proto.RegisterType((*InsertRequest)(nil), "index.InsertRequest")
 	proto.RegisterType((*InsertResponse)(nil), "index.InsertResponse")
+	proto.RegisterType((*DeleteRequest)(nil), "index.DeleteRequest")
+	proto.RegisterType((*DeleteResponse)(nil), "index.DeleteResponse")
 	proto.RegisterType((*Result)(nil), "index.Result")
 	proto.RegisterType((*Fingerprint)(nil), "index.Fingerprint")
+	proto.RegisterType((*PingRequest)(nil), "index.PingRequest")
+	proto.RegisterType((*PingResponse)(nil), "index.PingResponse")
+	proto.RegisterType((*GetAttributeRequest)(nil), "index.GetAttributeRequest")
+	proto.RegisterType((*GetAttributeResponse)(nil), "index.GetAttributeResponse")
+	proto.RegisterType((*SetAttributeRequest)(nil), "index.SetAttributeRequest")
+	proto.RegisterType((*SetAttributeResponse)(nil), "index.SetAttributeResponse")
+	proto.RegisterType((*GetStatsRequest)(nil), "index.GetStatsRequest")
+	proto.RegisterType((*GetStatsResponse)(nil), "index.GetStatsResponse")
 }
 
 func init() { proto.RegisterFile("index/index.proto", fileDescriptor_91013751fa82c1bb) }
 
 var fileDescriptor_91013751fa82c1bb = []byte{
-	// 253 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x64, 0x91, 0xcd, 0x4a, 0xc3, 0x40,
-	0x10, 0xc7, 0x9b, 0x68, 0x23, 0x4c, 0xdd, 0xa0, 0x83, 0x4a, 0xf0, 0x54, 0xf6, 0xd2, 0x1e, 0xa4,
-	0x42, 0x45, 0xc5, 0x17, 0x50, 0x7a, 0x5d, 0x9f, 0xa0, 0x9a, 0xd1, 0x2c, 0xc8, 0x26, 0xee, 0x6c,
-	0x41, 0xdf, 0x5e, 0xba, 0x1f, 0x69, 0x53, 0x2f, 0x21, 0xf3, 0xf1, 0x9b, 0xf9, 0xcf, 0x7f, 0xe1,
-	0x5c, 0x9b, 0x9a, 0x7e, 0x6e, 0xfd, 0x77, 0xd1, 0xd9, 0xd6, 0xb5, 0x38, 0xf6, 0x81, 0x9c, 0x81,
-	0x78, 0xa5, 0xb5, 0x7d, 0x6f, 0x14, 0x7d, 0x6f, 0x88, 0x1d, 0x5e, 0x41, 0xd1, 0xac, 0xb9, 0x21,
-	0xae, 0xb2, 0xe9, 0xd1, 0x5c, 0xa8, 0x18, 0xc9, 0x27, 0x28, 0x53, 0x23, 0x77, 0xad, 0x61, 0xc2,
-	0x19, 0x9c, 0x58, 0xe2, 0xcd, 0x97, 0x0b, 0xad, 0x93, 0xa5, 0x58, 0x84, 0x05, 0xca, 0x67, 0x55,
-	0xaa, 0xca, 0x17, 0x10, 0x2b, 0xc3, 0x64, 0x5d, 0xda, 0xf1, 0x00, 0xa7, 0x1f, 0xda, 0x7c, 0x92,
-	0xed, 0xac, 0x36, 0x3d, 0x8e, 0x11, 0x7f, 0xde, 0x95, 0xd4, 0xa0, 0x4f, 0x9e, 0x41, 0x99, 0x06,
-	0x05, 0x0d, 0xf2, 0x06, 0x8a, 0xb0, 0x0d, 0x4b, 0xc8, 0x75, 0x5d, 0x65, 0xd3, 0x6c, 0x2e, 0x54,
-	0xae, 0x6b, 0x44, 0x38, 0x6e, 0xb4, 0xe3, 0x2a, 0xf7, 0x19, 0xff, 0x2f, 0xef, 0x61, 0xb2, 0x37,
-	0xfc, 0x1f, 0xb2, 0x3b, 0x3d, 0xdf, 0x3f, 0x7d, 0xf9, 0x0b, 0xe3, 0xd5, 0x56, 0x19, 0x3e, 0x42,
-	0x11, 0x3c, 0xc0, 0x8b, 0xa8, 0x75, 0xe0, 0xdd, 0xf5, 0xe5, 0x41, 0x36, 0x8a, 0x1c, 0x6d, 0xc1,
-	0x20, 0xbc, 0x07, 0x07, 0x86, 0xf4, 0xe0, 0xc1, 0x75, 0xa3, 0xb7, 0xc2, 0x3f, 0xd6, 0xdd, 0x5f,
-	0x00, 0x00, 0x00, 0xff, 0xff, 0x80, 0x94, 0x0e, 0x53, 0xc1, 0x01, 0x00, 0x00,
+	// 501 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0x5b, 0x6f, 0xd3, 0x4c,
+	0x10, 0xfd, 0xe2, 0x5c, 0x3e, 0x98, 0xc4, 0xb9, 0x6c, 0x2e, 0x44, 0xe6, 0xa5, 0xec, 0x03, 0x6d,
+	0xa5, 0x52, 0x44, 0x11, 0x20, 0x1e, 0x10, 0x42, 0x54, 0x44, 0x79, 0x43, 0xf6, 0x0f, 0x88, 0x5c,
+	0x7b, 0x68, 0x56, 0x4a, 0xd6, 0xc1, 0xbb, 0xae, 0xf2, 0x43, 0xf8, 0x35, 0xfc, 0x3a, 0xe4, 0xbd,
+	0xf8, 0x92, 0x5a, 0x88, 0x97, 0x68, 0xf7, 0xcc, 0x39, 0x67, 0x66, 0x47, 0x27, 0x86, 0x09, 0xe3,
+	0x31, 0x1e, 0x5f, 0xab, 0xdf, 0xeb, 0x43, 0x9a, 0xc8, 0x84, 0x74, 0xd5, 0x85, 0x9e, 0x83, 0x1b,
+	0x60, 0x98, 0x46, 0x5b, 0x1f, 0x7f, 0x66, 0x28, 0x24, 0x59, 0x40, 0x6f, 0x1b, 0x8a, 0x2d, 0x8a,
+	0x65, 0xeb, 0xac, 0x7d, 0xe1, 0xfa, 0xe6, 0x46, 0x3f, 0xc2, 0xd0, 0x12, 0xc5, 0x21, 0xe1, 0x02,
+	0xc9, 0x39, 0xfc, 0x9f, 0xa2, 0xc8, 0x76, 0x52, 0x53, 0xfb, 0x37, 0xee, 0xb5, 0x6e, 0xe0, 0x2b,
+	0xd4, 0xb7, 0x55, 0xba, 0x02, 0x77, 0xcd, 0x05, 0xa6, 0xd2, 0xf6, 0x78, 0x0f, 0x83, 0x1f, 0x8c,
+	0xdf, 0x63, 0x7a, 0x48, 0x19, 0x2f, 0xe4, 0xc4, 0xc8, 0xbf, 0x95, 0x25, 0xbf, 0xc6, 0xa3, 0x63,
+	0x18, 0x5a, 0x23, 0x3d, 0x03, 0x7d, 0x01, 0xee, 0x2d, 0xee, 0x50, 0xa2, 0xb5, 0x1e, 0x43, 0x9b,
+	0xc5, 0x76, 0xf6, 0xfc, 0x98, 0x8b, 0x2c, 0xc5, 0x88, 0xae, 0xa0, 0xa7, 0x47, 0x24, 0x43, 0x70,
+	0x58, 0xbc, 0x6c, 0x9d, 0xb5, 0x2e, 0x5c, 0xdf, 0x61, 0x31, 0x21, 0xd0, 0xd9, 0x32, 0x29, 0x96,
+	0x8e, 0x42, 0xd4, 0x99, 0xbe, 0x83, 0x7e, 0x65, 0xa2, 0x47, 0x92, 0x72, 0x5f, 0x4e, 0x6d, 0x5f,
+	0x2e, 0xf4, 0xbf, 0x33, 0x7e, 0x6f, 0xe6, 0xa2, 0x43, 0x18, 0xe8, 0xab, 0x99, 0xe1, 0x12, 0xa6,
+	0x2b, 0x94, 0x5f, 0xa4, 0x4c, 0xd9, 0x5d, 0x56, 0x8e, 0x4f, 0xa0, 0xc3, 0xc3, 0x3d, 0x2a, 0xff,
+	0xa7, 0xbe, 0x3a, 0xd3, 0x2b, 0x98, 0xd5, 0xa9, 0x66, 0xff, 0x33, 0xe8, 0x3e, 0x84, 0xbb, 0xcc,
+	0x92, 0xf5, 0x85, 0x7e, 0x86, 0x69, 0xf0, 0x6f, 0xc6, 0xa5, 0x81, 0x53, 0x35, 0x58, 0xc0, 0x2c,
+	0x68, 0x68, 0x47, 0x27, 0x30, 0x5a, 0xa1, 0x0c, 0x64, 0x28, 0x85, 0x7d, 0xd4, 0xaf, 0x16, 0x8c,
+	0x4b, 0xcc, 0x8c, 0xf5, 0x12, 0x46, 0xfb, 0xf0, 0xb8, 0x89, 0x93, 0x28, 0xdb, 0x23, 0x97, 0x9b,
+	0x62, 0x5b, 0xee, 0x3e, 0x3c, 0xde, 0x1a, 0x74, 0x1d, 0x93, 0x57, 0x40, 0xc2, 0x48, 0xb2, 0x07,
+	0xdc, 0x44, 0x09, 0xe7, 0x18, 0x49, 0x96, 0x70, 0xbd, 0xf9, 0xae, 0x3f, 0xd1, 0x95, 0xaf, 0x65,
+	0x81, 0x5c, 0xc2, 0x98, 0xc5, 0xbb, 0x3a, 0xb9, 0xad, 0xc8, 0xa3, 0x1c, 0xaf, 0x50, 0x6f, 0x7e,
+	0xb7, 0xa1, 0xbb, 0xce, 0xa3, 0x44, 0x3e, 0x40, 0x4f, 0x87, 0x96, 0xcc, 0x4c, 0xb8, 0x6a, 0x61,
+	0xf7, 0xe6, 0x27, 0xa8, 0x79, 0xea, 0x7f, 0xb9, 0x50, 0x27, 0xad, 0x10, 0xd6, 0x12, 0xec, 0xcd,
+	0x4f, 0xd0, 0xaa, 0x50, 0xa7, 0xad, 0x10, 0xd6, 0xf2, 0xe9, 0xcd, 0x4f, 0xd0, 0x42, 0xf8, 0x06,
+	0x3a, 0x79, 0x40, 0x88, 0xfd, 0x17, 0x54, 0xc2, 0xe3, 0x4d, 0x6b, 0x58, 0x21, 0x59, 0xc3, 0xa0,
+	0x1a, 0x0c, 0xe2, 0x19, 0x5a, 0x43, 0xb0, 0xbc, 0xe7, 0x8d, 0xb5, 0xaa, 0x55, 0xd0, 0x64, 0x15,
+	0xfc, 0xc5, 0x2a, 0x68, 0xb6, 0xfa, 0x04, 0x4f, 0x6c, 0x26, 0xc8, 0xa2, 0xec, 0x5a, 0x0d, 0x8e,
+	0xf7, 0xec, 0x11, 0x6e, 0xe5, 0x77, 0x3d, 0xf5, 0x79, 0x7a, 0xfb, 0x67, 0x00, 0x48, 0x8d, 0x73,
+	0x6a, 0xb3, 0x04, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -311,6 +711,11 @@ const _ = grpc.SupportPackageIsVersion4
 type IndexClient interface {
 	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
 	Insert(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*InsertResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+	GetAttribute(ctx context.Context, in *GetAttributeRequest, opts ...grpc.CallOption) (*GetAttributeResponse, error)
+	SetAttribute(ctx context.Context, in *SetAttributeRequest, opts ...grpc.CallOption) (*SetAttributeResponse, error)
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
 }
 
 type indexClient struct {
@@ -339,10 +744,60 @@ func (c *indexClient) Insert(ctx context.Context, in *InsertRequest, opts ...grp
 	return out, nil
 }
 
+func (c *indexClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/index.Index/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	out := new(PingResponse)
+	err := c.cc.Invoke(ctx, "/index.Index/Ping", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexClient) GetAttribute(ctx context.Context, in *GetAttributeRequest, opts ...grpc.CallOption) (*GetAttributeResponse, error) {
+	out := new(GetAttributeResponse)
+	err := c.cc.Invoke(ctx, "/index.Index/GetAttribute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexClient) SetAttribute(ctx context.Context, in *SetAttributeRequest, opts ...grpc.CallOption) (*SetAttributeResponse, error) {
+	out := new(SetAttributeResponse)
+	err := c.cc.Invoke(ctx, "/index.Index/SetAttribute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, "/index.Index/GetStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // IndexServer is the server API for Index service.
 type IndexServer interface {
 	Search(context.Context, *SearchRequest) (*SearchResponse, error)
 	Insert(context.Context, *InsertRequest) (*InsertResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
+	GetAttribute(context.Context, *GetAttributeRequest) (*GetAttributeResponse, error)
+	SetAttribute(context.Context, *SetAttributeRequest) (*SetAttributeResponse, error)
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
 }
 
 // UnimplementedIndexServer can be embedded to have forward compatible implementations.
@@ -355,6 +810,21 @@ func (*UnimplementedIndexServer) Search(ctx context.Context, req *SearchRequest)
 func (*UnimplementedIndexServer) Insert(ctx context.Context, req *InsertRequest) (*InsertResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Insert not implemented")
 }
+func (*UnimplementedIndexServer) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (*UnimplementedIndexServer) Ping(ctx context.Context, req *PingRequest) (*PingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
+}
+func (*UnimplementedIndexServer) GetAttribute(ctx context.Context, req *GetAttributeRequest) (*GetAttributeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAttribute not implemented")
+}
+func (*UnimplementedIndexServer) SetAttribute(ctx context.Context, req *SetAttributeRequest) (*SetAttributeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetAttribute not implemented")
+}
+func (*UnimplementedIndexServer) GetStats(ctx context.Context, req *GetStatsRequest) (*GetStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
 
 func RegisterIndexServer(s *grpc.Server, srv IndexServer) {
 	s.RegisterService(&_Index_serviceDesc, srv)
@@ -396,6 +866,96 @@ func _Index_Insert_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Index_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/index.Index/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Index_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/index.Index/Ping",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServer).Ping(ctx, req.(*PingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Index_GetAttribute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAttributeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServer).GetAttribute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/index.Index/GetAttribute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServer).GetAttribute(ctx, req.(*GetAttributeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Index_SetAttribute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetAttributeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServer).SetAttribute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/index.Index/SetAttribute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServer).SetAttribute(ctx, req.(*SetAttributeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Index_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/index.Index/GetStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Index_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "index.Index",
 	HandlerType: (*IndexServer)(nil),
@@ -408,6 +968,26 @@ var _Index_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Insert",
 			Handler:    _Index_Insert_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _Index_Delete_Handler,
+		},
+		{
+			MethodName: "Ping",
+			Handler:    _Index_Ping_Handler,
+		},
+		{
+			MethodName: "GetAttribute",
+			Handler:    _Index_GetAttribute_Handler,
+		},
+		{
+			MethodName: "SetAttribute",
+			Handler:    _Index_SetAttribute_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _Index_GetStats_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "index/index.proto",
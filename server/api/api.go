@@ -11,11 +11,20 @@ import (
 type API struct {
 	Mux                 *http.ServeMux
 	FingerprintSearcher services.FingerprintSearcher
+	SearchConfig        *v2.SearchConfig
+	MetadataService     services.MetadataService
+	TrackService        services.TrackService
+	ApplicationService  services.ApplicationService
+	AccountService      services.AccountService
+	SubmissionService   services.SubmissionService
+	RateLimiter         *RateLimiter
+	StatsCollector      *StatsCollector
 }
 
 func NewAPI() *API {
 	ws := &API{
-		Mux: http.NewServeMux(),
+		Mux:          http.NewServeMux(),
+		SearchConfig: v2.NewSearchConfig(),
 	}
 
 	ws.Mux.Handle("/metrics", promhttp.Handler())
@@ -31,12 +40,48 @@ func NewAPI() *API {
 	})
 
 	ws.Mux.HandleFunc("/v2/lookup", func(rw http.ResponseWriter, r *http.Request) {
-		handler := v2.NewLookupHandler(ws.FingerprintSearcher)
-		handler.ServeHTTP(rw, r)
+		var stats v2.LookupStatsRecorder
+		if ws.StatsCollector != nil {
+			stats = ws.StatsCollector
+		}
+		handler := v2.RequireApplication(ws.ApplicationService, v2.NewLookupHandler(ws.FingerprintSearcher, ws.SearchConfig, ws.MetadataService, ws.TrackService, stats))
+		ws.rateLimit(handler).ServeHTTP(rw, r)
+	})
+
+	ws.Mux.HandleFunc("/v2/submit", func(rw http.ResponseWriter, r *http.Request) {
+		handler := v2.RequireApplication(ws.ApplicationService, v2.NewSubmitHandler(ws.AccountService, ws.SubmissionService))
+		ws.rateLimit(handler).ServeHTTP(rw, r)
+	})
+
+	ws.Mux.HandleFunc("/v2/submission_status", func(rw http.ResponseWriter, r *http.Request) {
+		handler := v2.RequireApplication(ws.ApplicationService, v2.NewSubmissionStatusHandler(ws.SubmissionService))
+		ws.rateLimit(handler).ServeHTTP(rw, r)
+	})
+
+	ws.Mux.HandleFunc("/v2/track/list_by_mbid", func(rw http.ResponseWriter, r *http.Request) {
+		handler := v2.RequireApplication(ws.ApplicationService, v2.NewTrackListByMBIDHandler(ws.TrackService))
+		ws.rateLimit(handler).ServeHTTP(rw, r)
+	})
+
+	ws.Mux.HandleFunc("/v2/track/list_by_puid", func(rw http.ResponseWriter, r *http.Request) {
+		handler := v2.RequireApplication(ws.ApplicationService, v2.NewTrackListByPUIDHandler(ws.TrackService))
+		ws.rateLimit(handler).ServeHTTP(rw, r)
+	})
+
+	ws.Mux.HandleFunc("/v2/track/list_by_foreignid", func(rw http.ResponseWriter, r *http.Request) {
+		handler := v2.RequireApplication(ws.ApplicationService, v2.NewTrackListByForeignIDHandler(ws.TrackService))
+		ws.rateLimit(handler).ServeHTTP(rw, r)
 	})
 	return ws
 }
 
+func (ws *API) rateLimit(handler http.Handler) http.Handler {
+	if ws.RateLimiter == nil {
+		return handler
+	}
+	return ws.RateLimiter.Wrap(handler)
+}
+
 func (ws *API) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	ws.Mux.ServeHTTP(rw, r)
 }
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/acoustid/go-acoustid/server/api/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// RateLimit describes how many requests per second are allowed for one key,
+// and how many requests can be made at once after a period of inactivity.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRateLimit parses a rate limit in the "rate" or "rate:burst" format.
+// If the burst is not specified, it's the same as the rate, rounded up.
+func ParseRateLimit(str string) (RateLimit, error) {
+	var limit RateLimit
+	parts := strings.SplitN(str, ":", 2)
+	rate, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || rate < 0 {
+		return limit, fmt.Errorf("invalid rate %q", parts[0])
+	}
+	limit.Rate = rate
+	if len(parts) == 2 {
+		burst, err := strconv.Atoi(parts[1])
+		if err != nil || burst < 1 {
+			return limit, fmt.Errorf("invalid burst %q", parts[1])
+		}
+		limit.Burst = burst
+	} else {
+		limit.Burst = int(math.Ceil(rate))
+	}
+	return limit, nil
+}
+
+// Unlimited returns true if no rate limit should be applied.
+func (l RateLimit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+func (l RateLimit) emissionInterval() time.Duration {
+	return time.Duration(float64(time.Second) / l.Rate)
+}
+
+func (l RateLimit) burst() int {
+	if l.Burst < 1 {
+		return 1
+	}
+	return l.Burst
+}
+
+// RateLimitStore keeps the state of rate limiters. The limiters use the generic cell rate algorithm,
+// so the only state that needs to be stored is the theoretical arrival time of the next request.
+type RateLimitStore interface {
+	// Allow registers a request for the given key. If the request is over the limit,
+	// it returns false and the time after which the request can be retried.
+	Allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+}
+
+// MemoryRateLimitStore keeps the rate limiter state in memory. It's only suitable for running a single API server.
+type MemoryRateLimitStore struct {
+	mu          sync.Mutex
+	tats        map[string]time.Time
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		tats: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastCleanup) > time.Minute {
+		for k, tat := range s.tats {
+			if tat.Before(now) {
+				delete(s.tats, k)
+			}
+		}
+		s.lastCleanup = now
+	}
+
+	tat, exists := s.tats[key]
+	if !exists || tat.Before(now) {
+		tat = now
+	}
+	interval := limit.emissionInterval()
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-interval * time.Duration(limit.burst()))
+	if allowAt.After(now) {
+		return false, allowAt.Sub(now), nil
+	}
+	s.tats[key] = newTat
+	return true, 0, nil
+}
+
+var redisAllowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+local allow_at = new_tat - interval * burst
+if allow_at > now then
+	return allow_at - now
+end
+redis.call("SET", KEYS[1], new_tat, "PX", math.ceil((new_tat - now) / 1000))
+return 0
+`)
+
+// RedisRateLimitStore keeps the rate limiter state in Redis, so that it can be shared by multiple API servers.
+type RedisRateLimitStore struct {
+	Client    redis.Cmdable
+	KeyPrefix string
+}
+
+func NewRedisRateLimitStore(client redis.Cmdable) *RedisRateLimitStore {
+	return &RedisRateLimitStore{Client: client, KeyPrefix: "rl:"}
+}
+
+func (s *RedisRateLimitStore) Allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	now := time.Now().UnixNano() / int64(time.Microsecond)
+	interval := limit.emissionInterval().Microseconds()
+	retryAfter, err := redisAllowScript.Run(ctx, s.Client, []string{s.KeyPrefix + key}, now, interval, limit.burst()).Int64()
+	if err != nil {
+		return false, 0, err
+	}
+	if retryAfter > 0 {
+		return false, time.Duration(retryAfter) * time.Microsecond, nil
+	}
+	return true, 0, nil
+}
+
+type RateLimiterConfig struct {
+	// IP is the limit applied to each client IP address.
+	IP RateLimit
+	// Application is the limit applied to each API key.
+	Application RateLimit
+	// ApplicationOverrides contains custom limits for specific API keys.
+	// Requests from these applications are not limited per IP address.
+	ApplicationOverrides map[string]RateLimit
+	// RealIPHeader is the name of the HTTP header with the client IP address, if running behind a proxy.
+	// The header can contain a comma-separated list of addresses, like X-Forwarded-For.
+	RealIPHeader string
+	// TrustedProxies are networks of proxies whose addresses are skipped in the RealIPHeader list.
+	// The client IP address is the right-most address in the list that is not a trusted proxy.
+	TrustedProxies []*net.IPNet
+}
+
+func NewRateLimiterConfig() *RateLimiterConfig {
+	return &RateLimiterConfig{
+		IP:                   RateLimit{Rate: 3, Burst: 3},
+		ApplicationOverrides: make(map[string]RateLimit),
+	}
+}
+
+// RateLimiter limits the number of requests per client IP address and per application API key.
+type RateLimiter struct {
+	Store  RateLimitStore
+	Config *RateLimiterConfig
+}
+
+func NewRateLimiter(store RateLimitStore, config *RateLimiterConfig) *RateLimiter {
+	return &RateLimiter{Store: store, Config: config}
+}
+
+// Wrap returns a handler that rejects requests over the rate limit with ERROR_TOO_MANY_REQUESTS.
+func (l *RateLimiter) Wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ok, retryAfter, limit := l.allow(r)
+		if !ok {
+			format, err := v2.GetResponseFormat(r)
+			if err != nil {
+				format = v2.DefaultFormat
+			}
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			rw.Header().Set("Retry-After", strconv.Itoa(seconds))
+			message := fmt.Sprintf("rate limit (%v requests / second) exceeded, try again later", limit.Rate)
+			v2.WriteError(rw, format, v2.NewError(v2.ERROR_TOO_MANY_REQUESTS, message))
+			return
+		}
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+func (l *RateLimiter) allow(r *http.Request) (bool, time.Duration, RateLimit) {
+	ctx := r.Context()
+
+	if apiKey := r.FormValue("client"); apiKey != "" {
+		limit, exists := l.Config.ApplicationOverrides[apiKey]
+		if !exists {
+			limit = l.Config.Application
+		}
+		if !limit.Unlimited() {
+			ok, retryAfter, err := l.Store.Allow(ctx, "app:"+apiKey, limit)
+			if err != nil {
+				log.Printf("Failed to check application rate limit: %v", err)
+			} else if !ok {
+				return false, retryAfter, limit
+			}
+		}
+		if exists {
+			return true, 0, limit
+		}
+	}
+
+	limit := l.Config.IP
+	if !limit.Unlimited() {
+		ok, retryAfter, err := l.Store.Allow(ctx, "ip:"+l.clientIP(r), limit)
+		if err != nil {
+			log.Printf("Failed to check IP address rate limit: %v", err)
+		} else if !ok {
+			return false, retryAfter, limit
+		}
+	}
+	return true, 0, limit
+}
+
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.Config.RealIPHeader != "" {
+		ip := l.forwardedIP(r.Header[http.CanonicalHeaderKey(l.Config.RealIPHeader)])
+		if ip != nil {
+			return ip.String()
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedIP finds the client IP address in a list of addresses added by proxies, e.g. in the X-Forwarded-For header.
+// Each proxy appends the address it received the request from, so the list is walked from the right, skipping
+// trusted proxies. Addresses to the left of the first untrusted one could have been sent by the client and are ignored.
+// It returns nil if the list is empty or contains an invalid address.
+func (l *RateLimiter) forwardedIP(values []string) net.IP {
+	var addrs []string
+	for _, value := range values {
+		addrs = append(addrs, strings.Split(value, ",")...)
+	}
+	var ip net.IP
+	for i := len(addrs) - 1; i >= 0; i-- {
+		ip = net.ParseIP(strings.TrimSpace(addrs[i]))
+		if ip == nil {
+			return nil
+		}
+		if !l.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+func (l *RateLimiter) isTrustedProxy(ip net.IP) bool {
+	for _, network := range l.Config.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies parses a list of IP addresses or networks in the CIDR notation.
+func ParseTrustedProxies(strs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, str := range strs {
+		if !strings.Contains(str, "/") {
+			ip := net.ParseIP(str)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", str)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", str)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := ParseRateLimit("10")
+	require.NoError(t, err)
+	assert.Equal(t, RateLimit{Rate: 10, Burst: 10}, limit)
+
+	limit, err = ParseRateLimit("0.5:3")
+	require.NoError(t, err)
+	assert.Equal(t, RateLimit{Rate: 0.5, Burst: 3}, limit)
+
+	_, err = ParseRateLimit("x")
+	assert.Error(t, err)
+
+	_, err = ParseRateLimit("1:0")
+	assert.Error(t, err)
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+
+	limit := RateLimit{Rate: 2, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		ok, _, err := store.Allow(ctx, "foo", limit)
+		require.NoError(t, err)
+		assert.True(t, ok, "request %d", i)
+	}
+
+	ok, retryAfter, err := store.Allow(ctx, "foo", limit)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	ok, _, err = store.Allow(ctx, "bar", limit)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _, err = store.Allow(ctx, "foo", limit)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	server, err := miniredis.Run()
+	require.NoError(t, err)
+	defer server.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	store := NewRedisRateLimitStore(client)
+
+	limit := RateLimit{Rate: 1, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		ok, _, err := store.Allow(ctx, "foo", limit)
+		require.NoError(t, err)
+		assert.True(t, ok, "request %d", i)
+	}
+
+	ok, retryAfter, err := store.Allow(ctx, "foo", limit)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.True(t, retryAfter > 0 && retryAfter <= time.Second, "retryAfter=%v", retryAfter)
+	assert.True(t, server.Exists("rl:foo"))
+}
+
+func TestRateLimiter(t *testing.T) {
+	cfg := NewRateLimiterConfig()
+	cfg.IP = RateLimit{Rate: 1, Burst: 1}
+	cfg.ApplicationOverrides["special"] = RateLimit{Rate: 100, Burst: 100}
+	limiter := NewRateLimiter(NewMemoryRateLimitStore(), cfg)
+
+	handler := limiter.Wrap(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v2/lookup?"+query, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	rw := request("client=foo")
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	rw = request("client=foo&format=xml")
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+	assert.Equal(t, "application/xml", rw.Header().Get("Content-Type"))
+	assert.Contains(t, rw.Body.String(), "<code>14</code>")
+
+	for i := 0; i < 10; i++ {
+		rw = request("client=special")
+		assert.Equal(t, http.StatusOK, rw.Code)
+	}
+}
+
+func TestRateLimiter_ClientIP(t *testing.T) {
+	cfg := NewRateLimiterConfig()
+	cfg.RealIPHeader = "X-Forwarded-For"
+	var err error
+	cfg.TrustedProxies, err = ParseTrustedProxies([]string{"10.0.0.0/8", "2001:db8::1"})
+	require.NoError(t, err)
+	limiter := NewRateLimiter(NewMemoryRateLimitStore(), cfg)
+
+	tests := []struct {
+		header   []string
+		expected string
+	}{
+		{nil, "192.0.2.1"},
+		{[]string{" 198.51.100.1 "}, "198.51.100.1"},
+		{[]string{"203.0.113.1, 198.51.100.1, 10.0.0.1"}, "198.51.100.1"},
+		{[]string{"203.0.113.1, 198.51.100.1", "10.0.0.1, 2001:db8::1"}, "198.51.100.1"},
+		{[]string{"10.0.0.2,10.0.0.1"}, "10.0.0.2"},
+		{[]string{"198.51.100.1, garbage"}, "192.0.2.1"},
+		{[]string{""}, "192.0.2.1"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/v2/lookup", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header["X-Forwarded-For"] = test.header
+		assert.Equal(t, test.expected, limiter.clientIP(req), "%v", test.header)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	networks, err := ParseTrustedProxies([]string{"192.0.2.1", "10.0.0.0/8"})
+	require.NoError(t, err)
+	require.Len(t, networks, 2)
+	assert.Equal(t, "192.0.2.1/32", networks[0].String())
+	assert.Equal(t, "10.0.0.0/8", networks[1].String())
+
+	_, err = ParseTrustedProxies([]string{"foo"})
+	assert.Error(t, err)
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+type lookupStatsKey struct {
+	applicationID int
+	hour          time.Time
+}
+
+type lookupCounts struct {
+	hits   int
+	noHits int
+}
+
+// StatsCollector counts lookups per application and hour in memory and periodically
+// adds the counts to the stored statistics, so that handlers never wait for the database.
+type StatsCollector struct {
+	Service       services.StatsService
+	FlushInterval time.Duration
+
+	mu     sync.Mutex
+	counts map[lookupStatsKey]*lookupCounts
+	now    func() time.Time
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func NewStatsCollector(service services.StatsService, flushInterval time.Duration) *StatsCollector {
+	return &StatsCollector{
+		Service:       service,
+		FlushInterval: flushInterval,
+		counts:        make(map[lookupStatsKey]*lookupCounts),
+		now:           time.Now,
+	}
+}
+
+// RecordLookup counts one searched fingerprint, hit is true if it matched at least one track.
+func (c *StatsCollector) RecordLookup(applicationID int, hit bool) {
+	key := lookupStatsKey{applicationID: applicationID, hour: c.now().UTC().Truncate(time.Hour)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts, exists := c.counts[key]
+	if !exists {
+		counts = &lookupCounts{}
+		c.counts[key] = counts
+	}
+	if hit {
+		counts.hits++
+	} else {
+		counts.noHits++
+	}
+}
+
+// Flush writes the collected counts to the stats service. If that fails,
+// the counts are kept and will be written by the next flush.
+func (c *StatsCollector) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	counts := c.counts
+	c.counts = make(map[lookupStatsKey]*lookupCounts)
+	c.mu.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	stats := make([]services.LookupStats, 0, len(counts))
+	for key, count := range counts {
+		stats = append(stats, services.LookupStats{
+			ApplicationID: key.applicationID,
+			Hour:          key.hour,
+			Hits:          count.hits,
+			NoHits:        count.noHits,
+		})
+	}
+
+	err := c.Service.UpdateLookupStats(ctx, stats)
+	if err != nil {
+		c.mu.Lock()
+		for key, count := range counts {
+			current, exists := c.counts[key]
+			if !exists {
+				c.counts[key] = count
+				continue
+			}
+			current.hits += count.hits
+			current.noHits += count.noHits
+		}
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Start runs the periodic flushing in the background, until Close is called.
+func (c *StatsCollector) Start() {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := c.Flush(context.Background())
+				if err != nil {
+					log.Printf("Failed to flush lookup stats: %v", err)
+				}
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background flushing and writes the remaining counts.
+func (c *StatsCollector) Close() error {
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+		c.stop = nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return c.Flush(ctx)
+}
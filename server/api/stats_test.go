@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/acoustid/go-acoustid/server/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockStatsService struct {
+	stats []services.LookupStats
+	err   error
+}
+
+func (s *mockStatsService) UpdateLookupStats(ctx context.Context, stats []services.LookupStats) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.stats = append(s.stats, stats...)
+	return nil
+}
+
+func TestStatsCollector(t *testing.T) {
+	ctx := context.Background()
+	service := &mockStatsService{}
+	collector := NewStatsCollector(service, time.Minute)
+	now := time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC)
+	collector.now = func() time.Time { return now }
+
+	collector.RecordLookup(1, true)
+	collector.RecordLookup(1, true)
+	collector.RecordLookup(1, false)
+	collector.RecordLookup(2, false)
+
+	service.err = errors.New("database is down")
+	require.Error(t, collector.Flush(ctx))
+	assert.Empty(t, service.stats)
+
+	now = now.Add(time.Hour)
+	collector.RecordLookup(1, true)
+
+	service.err = nil
+	require.NoError(t, collector.Flush(ctx))
+	hour := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	assert.ElementsMatch(t, []services.LookupStats{
+		{ApplicationID: 1, Hour: hour, Hits: 2, NoHits: 1},
+		{ApplicationID: 2, Hour: hour, Hits: 0, NoHits: 1},
+		{ApplicationID: 1, Hour: hour.Add(time.Hour), Hits: 1, NoHits: 0},
+	}, service.stats)
+
+	service.stats = nil
+	require.NoError(t, collector.Flush(ctx))
+	assert.Empty(t, service.stats)
+}
+
+func TestStatsCollector_FlushOnClose(t *testing.T) {
+	service := &mockStatsService{}
+	collector := NewStatsCollector(service, time.Hour)
+	collector.Start()
+	collector.RecordLookup(1, true)
+	require.NoError(t, collector.Close())
+	require.Len(t, service.stats, 1)
+	assert.Equal(t, 1, service.stats[0].Hits)
+}
@@ -0,0 +1,67 @@
+package v2
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+type contextKey int
+
+const applicationContextKey contextKey = 0
+
+// WithApplication returns a copy of ctx with the authenticated application attached.
+func WithApplication(ctx context.Context, app *services.Application) context.Context {
+	return context.WithValue(ctx, applicationContextKey, app)
+}
+
+// ApplicationFromContext returns the authenticated application, or nil if the request was not authenticated.
+func ApplicationFromContext(ctx context.Context) *services.Application {
+	app, _ := ctx.Value(applicationContextKey).(*services.Application)
+	return app
+}
+
+type ApplicationAuthHandler struct {
+	Applications services.ApplicationService
+	Handler      http.Handler
+}
+
+// RequireApplication wraps the handler so that it's only called for requests with a valid
+// API key in the 'client' parameter. The application is available to the wrapped handler
+// via ApplicationFromContext.
+func RequireApplication(applications services.ApplicationService, handler http.Handler) http.Handler {
+	return &ApplicationAuthHandler{Applications: applications, Handler: handler}
+}
+
+func (handler *ApplicationAuthHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	format, err := GetResponseFormat(r)
+	if err != nil {
+		WriteError(rw, DefaultFormat, NewError(ERROR_INVALID_FORMAT, "invalid format"))
+		return
+	}
+
+	apiKey := r.FormValue("client")
+	if apiKey == "" {
+		WriteError(rw, format, NewError(ERROR_MISSING_PARAMETER, "missing parameter 'client'"))
+		return
+	}
+
+	app, err := handler.Applications.GetApplicationByAPIKey(r.Context(), apiKey)
+	if err != nil {
+		log.Printf("Failed to load application: %v", err)
+		WriteError(rw, format, NewError(ERROR_INTERNAL, "internal error"))
+		return
+	}
+	if app == nil {
+		WriteError(rw, format, NewError(ERROR_INVALID_APIKEY, "invalid API key"))
+		return
+	}
+	if !app.Active {
+		WriteError(rw, format, NewError(ERROR_NOT_ALLOWED, "application is not active"))
+		return
+	}
+
+	handler.Handler.ServeHTTP(rw, r.WithContext(WithApplication(r.Context(), app)))
+}
@@ -0,0 +1,67 @@
+package v2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/acoustid/go-acoustid/server/services"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockApplicationService struct {
+	apps map[string]*services.Application
+}
+
+func (s *mockApplicationService) GetApplicationByAPIKey(ctx context.Context, apiKey string) (*services.Application, error) {
+	return s.apps[apiKey], nil
+}
+
+func newTestApplicationService() *mockApplicationService {
+	return &mockApplicationService{
+		apps: map[string]*services.Application{
+			"active":   {ID: 1, APIKey: "active", Active: true},
+			"inactive": {ID: 2, APIKey: "inactive", Active: false},
+		},
+	}
+}
+
+func TestRequireApplication(t *testing.T) {
+	var app *services.Application
+	inner := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		app = ApplicationFromContext(r.Context())
+		rw.WriteHeader(http.StatusOK)
+	})
+	handler := RequireApplication(newTestApplicationService(), inner)
+
+	tests := []struct {
+		client string
+		status int
+		body   string
+	}{
+		{"", http.StatusBadRequest, `"code":2`},
+		{"unknown", http.StatusBadRequest, `"code":4`},
+		{"inactive", http.StatusBadRequest, `"code":12`},
+		{"active", http.StatusOK, ""},
+	}
+	for _, test := range tests {
+		app = nil
+		rw := doLookup(t, handler, url.Values{"client": {test.client}})
+		assert.Equal(t, test.status, rw.Code, "client=%q", test.client)
+		assert.Contains(t, rw.Body.String(), test.body, "client=%q", test.client)
+		if test.status == http.StatusOK {
+			if assert.NotNil(t, app) {
+				assert.Equal(t, 1, app.ID)
+			}
+		} else {
+			assert.Nil(t, app)
+		}
+	}
+}
+
+func TestApplicationFromContext_Empty(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	assert.Nil(t, ApplicationFromContext(req.Context()))
+}
@@ -6,6 +6,12 @@ import (
 	"net/http"
 )
 
+// Error codes are part of the public API, clients check them to decide what to do with a failed request,
+// so existing codes must never be renumbered or reused. Codes 1 to 18 are the same as in the legacy
+// API server. Codes from 19 on are only returned by this server:
+//
+//	ERROR_TOO_MANY_ITEMS     a batch request has more than MaxBatchSize items
+//	ERROR_INVALID_PARAMETER  a parameter has a malformed value and there is no more specific code for it
 const ERROR_INVALID_FORMAT = 1
 const ERROR_MISSING_PARAMETER = 2
 const ERROR_INVALID_FINGERPRINT = 3
@@ -24,6 +30,8 @@ const ERROR_INVALID_MUSICBRAINZ_ACCESS_TOKEN = 15
 const ERROR_INSECURE_REQUEST = 16
 const ERROR_UNKNOWN_APPLICATION = 17
 const ERROR_FINGERPRINT_NOT_FOUND = 18
+const ERROR_TOO_MANY_ITEMS = 19
+const ERROR_INVALID_PARAMETER = 20
 
 type ErrorDetails struct {
 	Code    int      `json:"code" xml:"code"`
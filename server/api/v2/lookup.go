@@ -3,9 +3,8 @@ package v2
 import (
 	"context"
 	"log"
-	"math"
 	"net/http"
-	"strconv"
+	"sync"
 	"time"
 
 	"github.com/acoustid/go-acoustid/chromaprint"
@@ -16,46 +15,62 @@ const MinDuration = 1
 const MaxDuration = 32767
 
 type MetaArtist struct {
-	XMLName struct{} `json:"-" xml:"track"`
-	ID      string   `json:"id" xml:"id"`
-	Name    string   `json:"name,omitempty" xml:"name,omitempty"`
+	XMLName    struct{} `json:"-" xml:"artist"`
+	ID         string   `json:"id" xml:"id"`
+	Name       string   `json:"name,omitempty" xml:"name,omitempty"`
+	JoinPhrase string   `json:"joinphrase,omitempty" xml:"joinphrase,omitempty"`
 }
 
-type MetaRelease struct {
-	XMLName struct{} `json:"-" xml:"track"`
-	ID      string   `json:"id" xml:"id"`
-	Title   string   `json:"title,omitempty" xml:"title,omitempty"`
+type MetaTrack struct {
+	XMLName  struct{}     `json:"-" xml:"track"`
+	ID       string       `json:"id" xml:"id"`
+	Title    string       `json:"title,omitempty" xml:"title,omitempty"`
+	Artists  []MetaArtist `json:"artists,omitempty" xml:"artists>artist,omitempty"`
+	Position int          `json:"position,omitempty" xml:"position,omitempty"`
 }
 
 type MetaMedium struct {
-	XMLName    struct{}    `json:"-" xml:"track"`
-	ID         string      `json:"id" xml:"id"`
-	Position   int32       `json:"position,omitempty" xml:"position,omitempty"`
-	TrackCount int32       `json:"track_count,omitempty" xml:"track_count,omitempty"`
+	XMLName    struct{}    `json:"-" xml:"medium"`
+	Position   int         `json:"position,omitempty" xml:"position,omitempty"`
+	TrackCount int         `json:"track_count,omitempty" xml:"track_count,omitempty"`
 	Format     string      `json:"format,omitempty" xml:"format,omitempty"`
-	Release    MetaRelease `json:"release,omitempty" xml:"release,omitempty"`
+	Tracks     []MetaTrack `json:"tracks,omitempty" xml:"tracks>track,omitempty"`
 }
 
-type MetaTrack struct {
-	XMLName  struct{}     `json:"-" xml:"track"`
-	ID       string       `json:"id" xml:"id"`
-	Title    string       `json:"title,omitempty" xml:"title,omitempty"`
-	Duration float64      `json:"duration,omitempty" xml:"duration,omitempty"`
-	Artists  []MetaArtist `json:"artists,omitempty" xml:"artist,omitempty"`
-	Position int32        `json:"position,omitempty" xml:"position,omitempty"`
+type MetaRelease struct {
+	XMLName     struct{}     `json:"-" xml:"release"`
+	ID          string       `json:"id" xml:"id"`
+	Title       string       `json:"title,omitempty" xml:"title,omitempty"`
+	Artists     []MetaArtist `json:"artists,omitempty" xml:"artists>artist,omitempty"`
+	MediumCount int          `json:"medium_count,omitempty" xml:"medium_count,omitempty"`
+	Mediums     []MetaMedium `json:"mediums,omitempty" xml:"mediums>medium,omitempty"`
+}
+
+type MetaReleaseGroup struct {
+	XMLName  struct{}      `json:"-" xml:"releasegroup"`
+	ID       string        `json:"id" xml:"id"`
+	Title    string        `json:"title,omitempty" xml:"title,omitempty"`
+	Type     string        `json:"type,omitempty" xml:"type,omitempty"`
+	Artists  []MetaArtist  `json:"artists,omitempty" xml:"artists>artist,omitempty"`
+	Releases []MetaRelease `json:"releases,omitempty" xml:"releases>release,omitempty"`
 }
 
 type MetaRecording struct {
-	XMLName  struct{}    `json:"-" xml:"recording"`
-	ID       string      `json:"id" xml:"id"`
-	Duration float64     `json:"duration,omitempty" xml:"duration,omitempty"`
-	Tracks   []MetaTrack `json:"tracks,omitempty" xml:"tracks,omitempty"`
+	XMLName       struct{}           `json:"-" xml:"recording"`
+	ID            string             `json:"id" xml:"id"`
+	Title         string             `json:"title,omitempty" xml:"title,omitempty"`
+	Duration      float64            `json:"duration,omitempty" xml:"duration,omitempty"`
+	Artists       []MetaArtist       `json:"artists,omitempty" xml:"artists>artist,omitempty"`
+	Releases      []MetaRelease      `json:"releases,omitempty" xml:"releases>release,omitempty"`
+	ReleaseGroups []MetaReleaseGroup `json:"releasegroups,omitempty" xml:"releasegroups>releasegroup,omitempty"`
 }
 
 type LookupResult struct {
 	ID         string          `json:"id" xml:"id"`
 	Score      float64         `json:"score" xml:"score"`
-	Recordings []MetaRecording `json:"recordings,omitempty" xml:"recordings,omitempty"`
+	Recordings []MetaRecording `json:"recordings,omitempty" xml:"recordings>recording,omitempty"`
+	ForeignIDs []string        `json:"foreignids,omitempty" xml:"foreignids>foreignid,omitempty"`
+	Sources    int             `json:"sources,omitempty" xml:"sources,omitempty"`
 	XMLName    struct{}        `json:"-" xml:"result"`
 }
 
@@ -65,12 +80,211 @@ type LookupResponse struct {
 	XMLName struct{}       `json:"-" xml:"response"`
 }
 
+type BatchLookupItem struct {
+	Index   int            `json:"index" xml:"index"`
+	Status  string         `json:"status" xml:"status"`
+	Results []LookupResult `json:"results" xml:"results>result"`
+	Error   *ErrorDetails  `json:"error,omitempty" xml:"error,omitempty"`
+	XMLName struct{}       `json:"-" xml:"fingerprint"`
+}
+
+type BatchLookupResponse struct {
+	Status       string            `json:"status" xml:"status"`
+	Fingerprints []BatchLookupItem `json:"fingerprints" xml:"fingerprints>fingerprint"`
+	XMLName      struct{}          `json:"-" xml:"response"`
+}
+
+// LookupTimeout is the deadline for searching all fingerprints in one request.
+const LookupTimeout = time.Second
+
+// maxConcurrentSearches limits the number of fingerprints searched in parallel for one batch request.
+const maxConcurrentSearches = 8
+
+// LookupStatsRecorder counts lookups for usage statistics.
+type LookupStatsRecorder interface {
+	RecordLookup(applicationID int, hit bool)
+}
+
 type LookupHandler struct {
-	Searcher services.FingerprintSearcher
+	Searcher     services.FingerprintSearcher
+	SearchConfig *SearchConfig
+	Metadata     services.MetadataService
+	Tracks       services.TrackService
+	Stats        LookupStatsRecorder
+}
+
+func NewLookupHandler(searcher services.FingerprintSearcher, searchConfig *SearchConfig, metadata services.MetadataService, tracks services.TrackService, stats LookupStatsRecorder) http.Handler {
+	return &LookupHandler{Searcher: searcher, SearchConfig: searchConfig, Metadata: metadata, Tracks: tracks, Stats: stats}
+}
+
+// lookupQuery is either a fingerprint search or a direct lookup of an AcoustID.
+type lookupQuery struct {
+	trackGID    string
+	fingerprint chromaprint.Fingerprint
+	duration    time.Duration
+}
+
+func parseLookupQuery(item ItemParams) (*lookupQuery, *Error) {
+	if item.Has("trackid") {
+		trackGID := item.Get("trackid")
+		if !IsValidUUID(trackGID) {
+			e := NewError(ERROR_INVALID_UUID, "invalid UUID")
+			return nil, &e
+		}
+		return &lookupQuery{trackGID: trackGID}, nil
+	}
+
+	durationStr := item.Get("duration")
+	if durationStr == "" {
+		return nil, missingParameterError(item.Name("duration"))
+	}
+	duration, err := ParseDuration(durationStr)
+	if err != nil {
+		log.Printf("Invalid duration: %s", err)
+		e := NewError(ERROR_INVALID_DURATION, "invalid duration")
+		return nil, &e
+	}
+
+	fingerprintStr := item.Get("fingerprint")
+	if fingerprintStr == "" {
+		return nil, missingParameterError(item.Name("fingerprint"))
+	}
+	fingerprint, err := chromaprint.ParseFingerprintString(fingerprintStr)
+	if err != nil {
+		log.Printf("Invalid fingerprint: %s", err)
+		e := NewError(ERROR_INVALID_FINGERPRINT, "invalid fingerprint")
+		return nil, &e
+	}
+
+	return &lookupQuery{fingerprint: fingerprint, duration: duration}, nil
+}
+
+type lookupItem struct {
+	index   int
+	query   *lookupQuery
+	results []services.FingerprintSearchResult
+	err     *Error
+}
+
+func (handler *LookupHandler) run(ctx context.Context, query *lookupQuery, opts services.SearchOptions) ([]services.FingerprintSearchResult, error) {
+	if query.trackGID == "" {
+		return handler.Searcher.Search(ctx, query.fingerprint, query.duration, opts)
+	}
+	track, err := handler.Tracks.GetTrackByGID(ctx, query.trackGID)
+	if err != nil || track == nil {
+		return nil, err
+	}
+	return []services.FingerprintSearchResult{{TrackID: track.ID, TrackGID: track.GID, Score: 1.0}}, nil
+}
+
+// search runs the queries of all valid items concurrently, sharing the deadline of ctx.
+// At most maxConcurrentSearches workers are started and they take the items one by one.
+func (handler *LookupHandler) search(ctx context.Context, items []lookupItem, opts services.SearchOptions) {
+	queue := make(chan *lookupItem, len(items))
+	for i := range items {
+		if items[i].query != nil {
+			queue <- &items[i]
+		}
+	}
+	close(queue)
+
+	workers := len(queue)
+	if workers > maxConcurrentSearches {
+		workers = maxConcurrentSearches
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				results, err := handler.run(ctx, item.query, opts)
+				if err != nil {
+					log.Printf("Failed to search: %v", err)
+					e := NewError(ERROR_INTERNAL, "internal error")
+					item.err = &e
+					continue
+				}
+				item.results = results
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (handler *LookupHandler) recordStats(ctx context.Context, items []lookupItem) {
+	if handler.Stats == nil {
+		return
+	}
+	app := ApplicationFromContext(ctx)
+	if app == nil {
+		return
+	}
+	for _, item := range items {
+		if item.query != nil && item.err == nil {
+			handler.Stats.RecordLookup(app.ID, len(item.results) > 0)
+		}
+	}
 }
 
-func NewLookupHandler(searcher services.FingerprintSearcher) http.Handler {
-	return &LookupHandler{Searcher: searcher}
+type lookupMetadata struct {
+	recordings map[int][]services.Recording
+	foreignIDs map[int][]services.ForeignID
+}
+
+// loadMetadata fetches metadata for the tracks found in all items with a single call to each metadata service method.
+func (handler *LookupHandler) loadMetadata(ctx context.Context, items []lookupItem, meta MetaOptions) (*lookupMetadata, error) {
+	var metadata lookupMetadata
+	if !meta.IncludeRecordings() && !meta.ForeignIDs {
+		return &metadata, nil
+	}
+	var trackIDs []int
+	seen := make(map[int]bool)
+	for _, item := range items {
+		for _, result := range item.results {
+			if !seen[result.TrackID] {
+				seen[result.TrackID] = true
+				trackIDs = append(trackIDs, result.TrackID)
+			}
+		}
+	}
+	if len(trackIDs) == 0 {
+		return &metadata, nil
+	}
+	var err error
+	if meta.IncludeRecordings() {
+		metadata.recordings, err = handler.Metadata.GetTrackRecordings(ctx, trackIDs, meta.ServiceOptions())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if meta.ForeignIDs {
+		metadata.foreignIDs, err = handler.Metadata.GetTrackForeignIDs(ctx, trackIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &metadata, nil
+}
+
+func convertLookupResults(results []services.FingerprintSearchResult, metadata *lookupMetadata, meta MetaOptions) []LookupResult {
+	converted := make([]LookupResult, len(results))
+	for i, result := range results {
+		converted[i] = LookupResult{
+			ID:    result.TrackGID,
+			Score: result.Score,
+		}
+		if metadata.recordings != nil {
+			converted[i].Recordings = convertRecordings(metadata.recordings[result.TrackID], meta)
+		}
+		if meta.Sources {
+			converted[i].Sources = result.SubmissionCount
+		}
+		if metadata.foreignIDs != nil {
+			converted[i].ForeignIDs = convertForeignIDs(metadata.foreignIDs[result.TrackID])
+		}
+	}
+	return converted
 }
 
 func (handler *LookupHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
@@ -80,53 +294,92 @@ func (handler *LookupHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	durationStr := r.FormValue("duration")
-	if durationStr == "" {
-		WriteError(rw, format, NewError(ERROR_MISSING_PARAMETER, "missing parameter 'duration'"))
+	_, single := r.Form["fingerprint"]
+	params, err := SplitItemParams(r.Form, "fingerprint")
+	if err == nil && len(params) == 0 {
+		_, single = r.Form["trackid"]
+		params, err = SplitItemParams(r.Form, "trackid")
+	}
+	if err != nil {
+		WriteError(rw, format, *tooManyItemsError())
 		return
 	}
-	durationFloat, err := strconv.ParseFloat(durationStr, 64)
-	if err != nil || durationFloat < MinDuration || durationFloat >= MaxDuration {
-		if err != nil {
-			log.Printf("Invalid duration: %s", err)
+	if len(params) == 0 {
+		single = true
+		params = []ItemParams{{form: r.Form}}
+	}
+
+	items := make([]lookupItem, len(params))
+	var firstError *Error
+	for i, param := range params {
+		items[i].index = param.Index
+		items[i].query, items[i].err = parseLookupQuery(param)
+		if items[i].err != nil && firstError == nil {
+			firstError = items[i].err
 		}
-		WriteError(rw, format, NewError(ERROR_INVALID_DURATION, "invalid duration"))
+	}
+	if single && firstError != nil {
+		WriteError(rw, format, *firstError)
 		return
 	}
-	duration := time.Duration(math.Round(durationFloat * float64(time.Second)))
-	log.Printf("duration %v", duration)
 
-	fingerprintStr := r.FormValue("fingerprint")
-	if fingerprintStr == "" {
-		WriteError(rw, format, NewError(ERROR_MISSING_PARAMETER, "missing parameter 'fingerprint'"))
-		return
+	searchConfig := handler.SearchConfig
+	if searchConfig == nil {
+		searchConfig = NewSearchConfig()
 	}
-	fingerprint, err := chromaprint.ParseFingerprintString(fingerprintStr)
-	if err != nil {
-		log.Printf("Invalid fingerprint: %s", err)
-		WriteError(rw, format, NewError(ERROR_INVALID_FINGERPRINT, "invalid fingerprint"))
+	opts, e := searchConfig.ParseSearchOptions(r)
+	if e != nil {
+		WriteError(rw, format, *e)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+	meta := ParseMetaOptions(r.FormValue("meta"))
+
+	ctx, cancel := context.WithTimeout(r.Context(), LookupTimeout)
 	defer cancel()
 
-	results, err := handler.Searcher.Search(ctx, fingerprint, duration)
+	handler.search(ctx, items, opts)
+	handler.recordStats(r.Context(), items)
+	if single && items[0].err != nil {
+		WriteError(rw, format, *items[0].err)
+		return
+	}
+
+	metadata, err := handler.loadMetadata(ctx, items, meta)
 	if err != nil {
-		log.Printf("Failed to search: %v", err)
+		log.Printf("Failed to load metadata: %v", err)
 		WriteError(rw, format, NewError(ERROR_INTERNAL, "internal error"))
 		return
 	}
 
-	response := LookupResponse{
-		Status:  "ok",
-		Results: make([]LookupResult, len(results)),
-	}
-	for i, result := range results {
-		response.Results[i] = LookupResult{
-			ID:    result.TrackGID,
-			Score: result.Score,
+	var response interface{}
+	if single {
+		response = LookupResponse{
+			Status:  "ok",
+			Results: convertLookupResults(items[0].results, metadata, meta),
+		}
+	} else {
+		batchResponse := BatchLookupResponse{
+			Status:       "ok",
+			Fingerprints: make([]BatchLookupItem, len(items)),
+		}
+		for i, item := range items {
+			if item.err != nil {
+				batchResponse.Fingerprints[i] = BatchLookupItem{
+					Index:   item.index,
+					Status:  "error",
+					Results: []LookupResult{},
+					Error:   &item.err.ErrorDetails,
+				}
+				continue
+			}
+			batchResponse.Fingerprints[i] = BatchLookupItem{
+				Index:   item.index,
+				Status:  "ok",
+				Results: convertLookupResults(item.results, metadata, meta),
+			}
 		}
+		response = batchResponse
 	}
 
 	err = WriteResponse(rw, http.StatusOK, format, response)
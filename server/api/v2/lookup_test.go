@@ -0,0 +1,399 @@
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/acoustid/go-acoustid/chromaprint"
+	"github.com/acoustid/go-acoustid/server/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockFingerprintSearcher struct {
+	results []services.FingerprintSearchResult
+	opts    services.SearchOptions
+}
+
+func (s *mockFingerprintSearcher) Search(ctx context.Context, fingerprint chromaprint.Fingerprint, duration time.Duration, opts services.SearchOptions) ([]services.FingerprintSearchResult, error) {
+	s.opts = opts
+	return s.results, nil
+}
+
+type mockMetadataService struct {
+	recordings map[int][]services.Recording
+	foreignIDs map[int][]services.ForeignID
+	opts       services.MetadataOptions
+}
+
+func (s *mockMetadataService) GetTrackRecordings(ctx context.Context, trackIDs []int, opts services.MetadataOptions) (map[int][]services.Recording, error) {
+	s.opts = opts
+	return s.recordings, nil
+}
+
+func (s *mockMetadataService) GetTrackForeignIDs(ctx context.Context, trackIDs []int) (map[int][]services.ForeignID, error) {
+	return s.foreignIDs, nil
+}
+
+type mockTrackService struct {
+	tracks          map[string]services.TrackRef
+	mbids           map[string][]services.LinkedTrack
+	puids           map[string][]services.LinkedTrack
+	foreignIDs      map[string][]services.LinkedTrack
+	includeDisabled bool
+}
+
+func (s *mockTrackService) GetTrackByGID(ctx context.Context, gid string) (*services.TrackRef, error) {
+	track, exists := s.tracks[gid]
+	if !exists {
+		return nil, nil
+	}
+	return &track, nil
+}
+
+func (s *mockTrackService) GetTracksByMBIDs(ctx context.Context, mbids []string, includeDisabled bool) (map[string][]services.LinkedTrack, error) {
+	s.includeDisabled = includeDisabled
+	return s.mbids, nil
+}
+
+func (s *mockTrackService) GetTracksByPUIDs(ctx context.Context, puids []string) (map[string][]services.LinkedTrack, error) {
+	return s.puids, nil
+}
+
+func (s *mockTrackService) GetTracksByForeignIDs(ctx context.Context, foreignIDs []services.ForeignID) (map[string][]services.LinkedTrack, error) {
+	return s.foreignIDs, nil
+}
+
+const testFingerprint = "AQAACkGCIAmCBEGSIEgA"
+
+func newTestLookupHandler() (*LookupHandler, *mockMetadataService) {
+	searcher := &mockFingerprintSearcher{
+		results: []services.FingerprintSearchResult{
+			{TrackID: 1, TrackGID: "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e", Score: 0.9, SubmissionCount: 15},
+		},
+	}
+	metadata := &mockMetadataService{
+		recordings: map[int][]services.Recording{
+			1: {
+				{
+					ID:       "b8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f",
+					Title:    "Sunrise",
+					Duration: 215 * time.Second,
+					Artists:  []services.Artist{{ID: "c1d2e3f4-0000-4000-8000-000000000001", Name: "Calibre"}},
+					Releases: []services.Release{
+						{
+							ID:           "d1e2f3a4-0000-4000-8000-000000000002",
+							Title:        "Even If",
+							MediumCount:  1,
+							ReleaseGroup: services.ReleaseGroup{ID: "e1f2a3b4-0000-4000-8000-000000000003", Title: "Even If", Type: "Album"},
+							Mediums: []services.Medium{
+								{Position: 1, Format: "CD", TrackCount: 12, Tracks: []services.Track{{ID: "f1a2b3c4-0000-4000-8000-000000000004", Title: "Sunrise", Position: 3}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	metadata.foreignIDs = map[int][]services.ForeignID{
+		1: {{Vendor: "spotify", Name: "track:123"}},
+	}
+	tracks := &mockTrackService{
+		tracks: map[string]services.TrackRef{
+			"a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e": {ID: 1, GID: "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e"},
+			"0f4e4b1c-3b5a-4c2d-8e1f-6a7b8c9d0e1f": {ID: 1, GID: "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e"},
+		},
+	}
+	return &LookupHandler{Searcher: searcher, Metadata: metadata, Tracks: tracks}, metadata
+}
+
+func doLookup(t *testing.T, handler http.Handler, params url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/v2/lookup", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	return rw
+}
+
+func TestLookupHandler_NoMeta(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}})
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	var response LookupResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e", response.Results[0].ID)
+	assert.Empty(t, response.Results[0].Recordings)
+	assert.Equal(t, 0, response.Results[0].Sources)
+}
+
+func TestLookupHandler_MetaSources(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}, "meta": {"sources"}})
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	var response LookupResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, 15, response.Results[0].Sources)
+}
+
+func TestLookupHandler_MetaRecordings(t *testing.T) {
+	handler, metadata := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}, "meta": {"recordings releases"}})
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, services.MetadataOptions{Recordings: true, Releases: true}, metadata.opts)
+
+	var response LookupResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	require.Len(t, response.Results, 1)
+	require.Len(t, response.Results[0].Recordings, 1)
+	recording := response.Results[0].Recordings[0]
+	assert.Equal(t, "Sunrise", recording.Title)
+	assert.Equal(t, 215.0, recording.Duration)
+	assert.Equal(t, "Calibre", recording.Artists[0].Name)
+	require.Len(t, recording.Releases, 1)
+	assert.Equal(t, "Even If", recording.Releases[0].Title)
+	assert.Equal(t, 3, recording.Releases[0].Mediums[0].Tracks[0].Position)
+	assert.Empty(t, recording.ReleaseGroups)
+}
+
+func TestLookupHandler_MetaReleaseGroups(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}, "meta": {"recordingids+releasegroups+releaseids"}})
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	var response LookupResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	recording := response.Results[0].Recordings[0]
+	assert.Equal(t, "", recording.Title)
+	assert.Empty(t, recording.Releases)
+	require.Len(t, recording.ReleaseGroups, 1)
+	assert.Equal(t, "Album", recording.ReleaseGroups[0].Type)
+	require.Len(t, recording.ReleaseGroups[0].Releases, 1)
+	assert.Equal(t, "d1e2f3a4-0000-4000-8000-000000000002", recording.ReleaseGroups[0].Releases[0].ID)
+	assert.Equal(t, "", recording.ReleaseGroups[0].Releases[0].Title)
+}
+
+func TestLookupHandler_MetaXML(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}, "meta": {"recordings"}, "format": {"xml"}})
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), "<recordings><recording><id>b8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f</id><title>Sunrise</title>")
+	assert.Contains(t, rw.Body.String(), "<artists><artist><id>c1d2e3f4-0000-4000-8000-000000000001</id><name>Calibre</name></artist></artists>")
+}
+
+func TestLookupHandler_Batch(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{
+		"duration.0":    {"215"},
+		"fingerprint.0": {testFingerprint},
+		"duration.1":    {"215"},
+		"fingerprint.1": {"xxx"},
+		"duration.2":    {"215"},
+		"fingerprint.2": {testFingerprint},
+		"meta":          {"recordingids"},
+	})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var response BatchLookupResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	assert.Equal(t, "ok", response.Status)
+	require.Len(t, response.Fingerprints, 3)
+
+	for _, i := range []int{0, 2} {
+		item := response.Fingerprints[i]
+		assert.Equal(t, i, item.Index)
+		assert.Equal(t, "ok", item.Status)
+		require.Len(t, item.Results, 1)
+		assert.Equal(t, "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e", item.Results[0].ID)
+		require.Len(t, item.Results[0].Recordings, 1)
+		assert.Equal(t, "b8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f", item.Results[0].Recordings[0].ID)
+	}
+
+	item := response.Fingerprints[1]
+	assert.Equal(t, 1, item.Index)
+	assert.Equal(t, "error", item.Status)
+	assert.Empty(t, item.Results)
+	require.NotNil(t, item.Error)
+	assert.Equal(t, ERROR_INVALID_FINGERPRINT, item.Error.Code)
+}
+
+func TestLookupHandler_BatchXML(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{
+		"duration.0":    {"215"},
+		"fingerprint.0": {testFingerprint},
+		"fingerprint.1": {testFingerprint},
+		"format":        {"xml"},
+	})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Contains(t, rw.Body.String(), "<fingerprints><fingerprint><index>0</index><status>ok</status><results><result><id>a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e</id>")
+	assert.Contains(t, rw.Body.String(), "<fingerprint><index>1</index><status>error</status><results></results><error><code>2</code><message>missing parameter &#39;duration.1&#39;</message></error></fingerprint>")
+}
+
+func TestLookupHandler_MissingParameter(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{"fingerprint": {testFingerprint}})
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), "missing parameter 'duration'")
+}
+
+func TestLookupHandler_TrackID(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{"trackid": {"0f4e4b1c-3b5a-4c2d-8e1f-6a7b8c9d0e1f"}, "meta": {"recordingids"}})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var response LookupResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e", response.Results[0].ID)
+	assert.Equal(t, 1.0, response.Results[0].Score)
+	require.Len(t, response.Results[0].Recordings, 1)
+	assert.Equal(t, "b8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f", response.Results[0].Recordings[0].ID)
+}
+
+func TestLookupHandler_TrackIDNotFound(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{"trackid": {"00000000-0000-4000-8000-000000000000"}})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var response LookupResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	assert.Empty(t, response.Results)
+}
+
+func TestLookupHandler_InvalidTrackID(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{"trackid": {"foo"}})
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"code":7`)
+}
+
+func TestLookupHandler_MetaForeignIDs(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	rw := doLookup(t, handler, url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}, "meta": {"foreignids"}})
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	var response LookupResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, []string{"spotify:track:123"}, response.Results[0].ForeignIDs)
+	assert.Empty(t, response.Results[0].Recordings)
+
+	rw = doLookup(t, handler, url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}, "meta": {"foreignids"}, "format": {"xml"}})
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), "<foreignids><foreignid>spotify:track:123</foreignid></foreignids>")
+}
+
+type mockLookupStatsRecorder struct {
+	hits   map[int]int
+	noHits map[int]int
+}
+
+func (r *mockLookupStatsRecorder) RecordLookup(applicationID int, hit bool) {
+	if hit {
+		r.hits[applicationID]++
+	} else {
+		r.noHits[applicationID]++
+	}
+}
+
+func TestLookupHandler_Stats(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	stats := &mockLookupStatsRecorder{hits: make(map[int]int), noHits: make(map[int]int)}
+	handler.Stats = stats
+
+	params := url.Values{
+		"duration.0":    {"215"},
+		"fingerprint.0": {testFingerprint},
+		"fingerprint.1": {testFingerprint},
+	}
+	req := httptest.NewRequest("POST", "/v2/lookup", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(WithApplication(req.Context(), &services.Application{ID: 5, Active: true}))
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	assert.Equal(t, map[int]int{5: 1}, stats.hits)
+	assert.Empty(t, stats.noHits)
+}
+
+func TestLookupHandler_MaxDurationDiff(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	searcher := handler.Searcher.(*mockFingerprintSearcher)
+
+	rw := doLookup(t, handler, url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}})
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, services.DefaultMaxDurationDiff, searcher.opts.MaxDurationDiff)
+
+	rw = doLookup(t, handler, url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}, "maxdurationdiff": {"20"}})
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, 20*time.Second, searcher.opts.MaxDurationDiff)
+
+	rw = doLookup(t, handler, url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}, "maxdurationdiff": {"-1"}})
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"code":11`)
+}
+
+func TestLookupHandler_TooManyItems(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	params := url.Values{}
+	for i := 0; i <= MaxBatchSize; i++ {
+		params.Set(fmt.Sprintf("duration.%d", i), "215")
+		params.Set(fmt.Sprintf("fingerprint.%d", i), testFingerprint)
+	}
+	rw := doLookup(t, handler, params)
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	assert.Equal(t, ERROR_TOO_MANY_ITEMS, response.Error.Code)
+}
+
+func TestLookupHandler_BatchConcurrency(t *testing.T) {
+	handler, _ := newTestLookupHandler()
+	searcher := &blockingFingerprintSearcher{release: make(chan struct{})}
+	handler.Searcher = searcher
+	params := url.Values{}
+	for i := 0; i < 3*maxConcurrentSearches; i++ {
+		params.Set(fmt.Sprintf("duration.%d", i), "215")
+		params.Set(fmt.Sprintf("fingerprint.%d", i), testFingerprint)
+	}
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- doLookup(t, handler, params) }()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&searcher.active) == maxConcurrentSearches }, time.Second, time.Millisecond)
+	close(searcher.release)
+	rw := <-done
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Equal(t, int32(maxConcurrentSearches), searcher.maxActive)
+}
+
+type blockingFingerprintSearcher struct {
+	release   chan struct{}
+	active    int32
+	maxActive int32
+}
+
+func (s *blockingFingerprintSearcher) Search(ctx context.Context, fingerprint chromaprint.Fingerprint, duration time.Duration, opts services.SearchOptions) ([]services.FingerprintSearchResult, error) {
+	active := atomic.AddInt32(&s.active, 1)
+	defer atomic.AddInt32(&s.active, -1)
+	for {
+		maxActive := atomic.LoadInt32(&s.maxActive)
+		if active <= maxActive || atomic.CompareAndSwapInt32(&s.maxActive, maxActive, active) {
+			break
+		}
+	}
+	<-s.release
+	return nil, nil
+}
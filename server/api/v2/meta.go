@@ -0,0 +1,166 @@
+package v2
+
+import (
+	"strings"
+
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+// MetaOptions describes which metadata should be included in lookup results.
+type MetaOptions struct {
+	RecordingIDs    bool
+	Recordings      bool
+	ReleaseIDs      bool
+	Releases        bool
+	ReleaseGroupIDs bool
+	ReleaseGroups   bool
+	ForeignIDs      bool
+	Sources         bool
+}
+
+// ParseMetaOptions parses the value of the 'meta' parameter. Unknown values are ignored.
+func ParseMetaOptions(str string) MetaOptions {
+	var meta MetaOptions
+	items := strings.FieldsFunc(str, func(r rune) bool {
+		return r == ' ' || r == '+' || r == ','
+	})
+	for _, item := range items {
+		switch item {
+		case "recordingids":
+			meta.RecordingIDs = true
+		case "recordings":
+			meta.Recordings = true
+		case "releaseids":
+			meta.ReleaseIDs = true
+		case "releases":
+			meta.Releases = true
+		case "releasegroupids":
+			meta.ReleaseGroupIDs = true
+		case "releasegroups":
+			meta.ReleaseGroups = true
+		case "foreignids":
+			meta.ForeignIDs = true
+		case "sources":
+			meta.Sources = true
+		}
+	}
+	return meta
+}
+
+func (meta MetaOptions) includeReleases() bool {
+	return meta.ReleaseIDs || meta.Releases
+}
+
+func (meta MetaOptions) includeReleaseGroups() bool {
+	return meta.ReleaseGroupIDs || meta.ReleaseGroups
+}
+
+// IncludeRecordings returns true if the results should contain any recording information.
+func (meta MetaOptions) IncludeRecordings() bool {
+	return meta.RecordingIDs || meta.Recordings || meta.includeReleases() || meta.includeReleaseGroups()
+}
+
+// ServiceOptions returns options for loading the metadata needed by this output configuration.
+func (meta MetaOptions) ServiceOptions() services.MetadataOptions {
+	return services.MetadataOptions{
+		Recordings: meta.Recordings,
+		Releases:   meta.includeReleases() || meta.includeReleaseGroups(),
+	}
+}
+
+func convertArtists(artists []services.Artist) []MetaArtist {
+	if len(artists) == 0 {
+		return nil
+	}
+	result := make([]MetaArtist, len(artists))
+	for i, artist := range artists {
+		result[i] = MetaArtist{
+			ID:         artist.ID,
+			Name:       artist.Name,
+			JoinPhrase: artist.JoinPhrase,
+		}
+	}
+	return result
+}
+
+func convertRelease(release services.Release, meta MetaOptions) MetaRelease {
+	result := MetaRelease{ID: release.ID}
+	if meta.Releases {
+		result.Title = release.Title
+		result.Artists = convertArtists(release.Artists)
+		result.MediumCount = release.MediumCount
+		for _, medium := range release.Mediums {
+			m := MetaMedium{
+				Position:   medium.Position,
+				TrackCount: medium.TrackCount,
+				Format:     medium.Format,
+			}
+			for _, track := range medium.Tracks {
+				m.Tracks = append(m.Tracks, MetaTrack{
+					ID:       track.ID,
+					Title:    track.Title,
+					Artists:  convertArtists(track.Artists),
+					Position: track.Position,
+				})
+			}
+			result.Mediums = append(result.Mediums, m)
+		}
+	}
+	return result
+}
+
+func convertRecording(recording services.Recording, meta MetaOptions) MetaRecording {
+	result := MetaRecording{ID: recording.ID}
+	if meta.Recordings {
+		result.Title = recording.Title
+		result.Duration = recording.Duration.Seconds()
+		result.Artists = convertArtists(recording.Artists)
+	}
+	if meta.includeReleaseGroups() {
+		index := make(map[string]int)
+		for _, release := range recording.Releases {
+			i, exists := index[release.ReleaseGroup.ID]
+			if !exists {
+				releaseGroup := MetaReleaseGroup{ID: release.ReleaseGroup.ID}
+				if meta.ReleaseGroups {
+					releaseGroup.Title = release.ReleaseGroup.Title
+					releaseGroup.Type = release.ReleaseGroup.Type
+					releaseGroup.Artists = convertArtists(release.ReleaseGroup.Artists)
+				}
+				i = len(result.ReleaseGroups)
+				index[release.ReleaseGroup.ID] = i
+				result.ReleaseGroups = append(result.ReleaseGroups, releaseGroup)
+			}
+			if meta.includeReleases() {
+				result.ReleaseGroups[i].Releases = append(result.ReleaseGroups[i].Releases, convertRelease(release, meta))
+			}
+		}
+	} else if meta.includeReleases() {
+		for _, release := range recording.Releases {
+			result.Releases = append(result.Releases, convertRelease(release, meta))
+		}
+	}
+	return result
+}
+
+func convertForeignIDs(foreignIDs []services.ForeignID) []string {
+	if len(foreignIDs) == 0 {
+		return nil
+	}
+	result := make([]string, len(foreignIDs))
+	for i, foreignID := range foreignIDs {
+		result[i] = foreignID.String()
+	}
+	return result
+}
+
+func convertRecordings(recordings []services.Recording, meta MetaOptions) []MetaRecording {
+	if len(recordings) == 0 {
+		return nil
+	}
+	result := make([]MetaRecording, len(recordings))
+	for i, recording := range recordings {
+		result[i] = convertRecording(recording, meta)
+	}
+	return result
+}
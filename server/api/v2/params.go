@@ -0,0 +1,109 @@
+package v2
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+// ItemParams gives access to parameters of one item in a request that can contain multiple items,
+// e.g. fingerprint.0, duration.0, fingerprint.1, duration.1, ...
+type ItemParams struct {
+	Index  int
+	form   url.Values
+	suffix string
+}
+
+// Name returns the full name of the parameter, including the item index.
+func (p ItemParams) Name(name string) string {
+	return name + p.suffix
+}
+
+// Get returns the first value of the named parameter of this item.
+func (p ItemParams) Get(name string) string {
+	return p.form.Get(name + p.suffix)
+}
+
+// GetAll returns all values of the named parameter of this item.
+func (p ItemParams) GetAll(name string) []string {
+	return p.form[name+p.suffix]
+}
+
+// Has returns true if the named parameter of this item is present.
+func (p ItemParams) Has(name string) bool {
+	_, exists := p.form[name+p.suffix]
+	return exists
+}
+
+// MaxBatchSize is the maximum number of items in one request.
+const MaxBatchSize = 100
+
+var errTooManyItems = errors.New("too many items")
+
+// SplitItemParams finds all items identified by the key parameter. If the parameter is present without an index,
+// the request contains a single item. Otherwise, items are identified by the numeric suffix and ordered by it.
+// Requests with more than MaxBatchSize items are rejected.
+func SplitItemParams(form url.Values, key string) ([]ItemParams, error) {
+	if _, exists := form[key]; exists {
+		return []ItemParams{{Index: 0, form: form}}, nil
+	}
+	var items []ItemParams
+	prefix := key + "."
+	for name := range form {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		suffix := strings.TrimPrefix(name, prefix)
+		index, err := strconv.Atoi(suffix)
+		if err != nil || index < 0 || strconv.Itoa(index) != suffix {
+			continue
+		}
+		if len(items) >= MaxBatchSize {
+			return nil, errTooManyItems
+		}
+		items = append(items, ItemParams{Index: index, form: form, suffix: "." + suffix})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Index < items[j].Index })
+	return items, nil
+}
+
+var errInvalidDuration = errors.New("invalid duration")
+
+// ParseDuration parses audio duration in seconds.
+func ParseDuration(str string) (time.Duration, error) {
+	durationFloat, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, err
+	}
+	if durationFloat < MinDuration || durationFloat >= MaxDuration {
+		return 0, errInvalidDuration
+	}
+	return time.Duration(math.Round(durationFloat * float64(time.Second))), nil
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// IsValidUUID returns true if str is a UUID in the canonical textual representation.
+func IsValidUUID(str string) bool {
+	return uuidRegexp.MatchString(str)
+}
+
+var foreignIDRegexp = regexp.MustCompile(`^([0-9a-z]+):(\S+)$`)
+
+var errInvalidForeignID = errors.New("invalid foreign ID")
+
+// ParseForeignID parses a foreign ID in the "vendor:name" format.
+func ParseForeignID(str string) (services.ForeignID, error) {
+	match := foreignIDRegexp.FindStringSubmatch(str)
+	if match == nil {
+		return services.ForeignID{}, errInvalidForeignID
+	}
+	return services.ForeignID{Vendor: match[1], Name: match[2]}, nil
+}
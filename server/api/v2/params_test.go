@@ -0,0 +1,65 @@
+package v2
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/acoustid/go-acoustid/server/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitItemParams_Single(t *testing.T) {
+	form := url.Values{"fingerprint": {"a"}, "fingerprint.1": {"b"}}
+	items, err := SplitItemParams(form, "fingerprint")
+	require.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, 0, items[0].Index)
+		assert.Equal(t, "a", items[0].Get("fingerprint"))
+		assert.Equal(t, "duration", items[0].Name("duration"))
+	}
+}
+
+func TestSplitItemParams_Multiple(t *testing.T) {
+	form := url.Values{"fingerprint.10": {"c"}, "fingerprint.2": {"b"}, "fingerprint.0": {"a"}, "fingerprint.x": {"x"}, "fingerprint.01": {"x"}}
+	items, err := SplitItemParams(form, "fingerprint")
+	require.NoError(t, err)
+	if assert.Len(t, items, 3) {
+		assert.Equal(t, []int{0, 2, 10}, []int{items[0].Index, items[1].Index, items[2].Index})
+		assert.Equal(t, "c", items[2].Get("fingerprint"))
+		assert.Equal(t, "duration.10", items[2].Name("duration"))
+	}
+}
+
+func TestSplitItemParams_TooMany(t *testing.T) {
+	form := url.Values{}
+	for i := 0; i <= MaxBatchSize; i++ {
+		form.Set(fmt.Sprintf("fingerprint.%d", i), "a")
+	}
+	_, err := SplitItemParams(form, "fingerprint")
+	assert.Error(t, err)
+
+	form.Del("fingerprint.0")
+	items, err := SplitItemParams(form, "fingerprint")
+	require.NoError(t, err)
+	assert.Len(t, items, MaxBatchSize)
+}
+
+func TestParseForeignID(t *testing.T) {
+	id, err := ParseForeignID("spotify:track:123")
+	if assert.NoError(t, err) {
+		assert.Equal(t, services.ForeignID{Vendor: "spotify", Name: "track:123"}, id)
+		assert.Equal(t, "spotify:track:123", id.String())
+	}
+	for _, str := range []string{"", "spotify", "spotify:", ":123", "Spotify:123", "spotify:1 2"} {
+		_, err = ParseForeignID(str)
+		assert.Error(t, err, "str=%q", str)
+	}
+}
+
+func TestIsValidUUID(t *testing.T) {
+	assert.True(t, IsValidUUID("b8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f"))
+	assert.False(t, IsValidUUID("b8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8"))
+	assert.False(t, IsValidUUID("b8f3a1e46f4a4a0b8f0e0f6b5c6d7e8f"))
+}
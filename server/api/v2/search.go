@@ -0,0 +1,87 @@
+package v2
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+// SearchConfig contains the default fingerprint search options and the limits
+// for options that clients can change in their requests.
+type SearchConfig struct {
+	Defaults services.SearchOptions
+	// MaxDurationDiff is the largest duration tolerance a client can request.
+	MaxDurationDiff time.Duration
+	// MaxResults is the largest number of results a client can request, 0 means no limit.
+	MaxResults int
+	// MaxCandidates is the largest number of index candidates a client can request.
+	MaxCandidates int
+}
+
+func NewSearchConfig() *SearchConfig {
+	return &SearchConfig{
+		Defaults:        services.NewSearchOptions(),
+		MaxDurationDiff: 30 * time.Second,
+		MaxCandidates:   50,
+	}
+}
+
+// ParseSearchOptions parses search parameters shared by all fingerprints in the request.
+// Values over the configured limits are rejected for maxdurationdiff and capped for the other parameters.
+func (cfg *SearchConfig) ParseSearchOptions(r *http.Request) (services.SearchOptions, *Error) {
+	opts := cfg.Defaults
+
+	maxDurationDiffStr := r.FormValue("maxdurationdiff")
+	if maxDurationDiffStr != "" {
+		maxDurationDiff, err := strconv.ParseFloat(maxDurationDiffStr, 64)
+		if err != nil || maxDurationDiff < 0 || maxDurationDiff >= MaxDuration {
+			e := NewError(ERROR_INVALID_MAX_DURATION_DIFF, "invalid max duration diff")
+			return opts, &e
+		}
+		opts.MaxDurationDiff = time.Duration(math.Round(maxDurationDiff * float64(time.Second)))
+		if opts.MaxDurationDiff > cfg.MaxDurationDiff {
+			e := NewError(ERROR_INVALID_MAX_DURATION_DIFF, "max duration diff is too large")
+			return opts, &e
+		}
+	}
+
+	maxResultsStr := r.FormValue("maxresults")
+	if maxResultsStr != "" {
+		maxResults, err := strconv.Atoi(maxResultsStr)
+		if err != nil || maxResults < 1 {
+			return opts, invalidParameterError("maxresults")
+		}
+		if cfg.MaxResults > 0 && maxResults > cfg.MaxResults {
+			maxResults = cfg.MaxResults
+		}
+		opts.MaxResults = maxResults
+	}
+
+	minScoreStr := r.FormValue("minscore")
+	if minScoreStr != "" {
+		minScore, err := strconv.ParseFloat(minScoreStr, 64)
+		if err != nil || minScore < 0 || minScore > 1 {
+			return opts, invalidParameterError("minscore")
+		}
+		if minScore > opts.MinScore {
+			opts.MinScore = minScore
+		}
+	}
+
+	maxCandidatesStr := r.FormValue("maxcandidates")
+	if maxCandidatesStr != "" {
+		maxCandidates, err := strconv.Atoi(maxCandidatesStr)
+		if err != nil || maxCandidates < 1 {
+			return opts, invalidParameterError("maxcandidates")
+		}
+		if maxCandidates > cfg.MaxCandidates {
+			maxCandidates = cfg.MaxCandidates
+		}
+		opts.MaxCandidates = maxCandidates
+	}
+
+	return opts, nil
+}
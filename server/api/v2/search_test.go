@@ -0,0 +1,56 @@
+package v2
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchConfig_ParseSearchOptions(t *testing.T) {
+	cfg := NewSearchConfig()
+	cfg.Defaults.MinScore = 0.5
+	cfg.MaxResults = 5
+
+	req := httptest.NewRequest("GET", "/v2/lookup", nil)
+	opts, e := cfg.ParseSearchOptions(req)
+	require.Nil(t, e)
+	assert.Equal(t, cfg.Defaults, opts)
+
+	req = httptest.NewRequest("GET", "/v2/lookup?maxdurationdiff=10.5&maxresults=3&minscore=0.8&maxcandidates=20", nil)
+	opts, e = cfg.ParseSearchOptions(req)
+	require.Nil(t, e)
+	expected := cfg.Defaults
+	expected.MaxDurationDiff = 10500 * time.Millisecond
+	expected.MaxResults = 3
+	expected.MinScore = 0.8
+	expected.MaxCandidates = 20
+	assert.Equal(t, expected, opts)
+}
+
+func TestSearchConfig_ParseSearchOptionsLimits(t *testing.T) {
+	cfg := NewSearchConfig()
+	cfg.Defaults.MinScore = 0.5
+	cfg.MaxResults = 5
+
+	req := httptest.NewRequest("GET", "/v2/lookup?maxresults=100&minscore=0.1&maxcandidates=1000", nil)
+	opts, e := cfg.ParseSearchOptions(req)
+	require.Nil(t, e)
+	assert.Equal(t, 5, opts.MaxResults)
+	assert.Equal(t, 0.5, opts.MinScore)
+	assert.Equal(t, 50, opts.MaxCandidates)
+
+	req = httptest.NewRequest("GET", "/v2/lookup?maxdurationdiff=60", nil)
+	_, e = cfg.ParseSearchOptions(req)
+	require.NotNil(t, e)
+	assert.Equal(t, ERROR_INVALID_MAX_DURATION_DIFF, e.Code)
+
+	for _, query := range []string{"maxresults=0", "minscore=2", "maxcandidates=x"} {
+		req = httptest.NewRequest("GET", "/v2/lookup?"+query, nil)
+		_, e = cfg.ParseSearchOptions(req)
+		require.NotNil(t, e, query)
+		assert.Equal(t, ERROR_INVALID_PARAMETER, e.Code, query)
+	}
+}
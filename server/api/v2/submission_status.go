@@ -0,0 +1,87 @@
+package v2
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+type SubmissionStatusTrack struct {
+	ID string `json:"id" xml:"id"`
+}
+
+type SubmissionStatusResult struct {
+	ID       int                    `json:"id" xml:"id"`
+	Status   string                 `json:"status" xml:"status"`
+	Response *SubmissionStatusTrack `json:"response,omitempty" xml:"response,omitempty"`
+	XMLName  struct{}               `json:"-" xml:"submission"`
+}
+
+type SubmissionStatusResponse struct {
+	Status      string                   `json:"status" xml:"status"`
+	Submissions []SubmissionStatusResult `json:"submissions" xml:"submissions>submission"`
+	XMLName     struct{}                 `json:"-" xml:"response"`
+}
+
+type SubmissionStatusHandler struct {
+	Submissions services.SubmissionService
+}
+
+func NewSubmissionStatusHandler(submissions services.SubmissionService) http.Handler {
+	return &SubmissionStatusHandler{Submissions: submissions}
+}
+
+func (handler *SubmissionStatusHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	format, err := GetResponseFormat(r)
+	if err != nil {
+		WriteError(rw, DefaultFormat, NewError(ERROR_INVALID_FORMAT, "invalid format"))
+		return
+	}
+
+	if len(r.Form["id"]) > MaxBatchSize {
+		WriteError(rw, format, *tooManyItemsError())
+		return
+	}
+
+	var ids []int
+	for _, idStr := range r.Form["id"] {
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			WriteError(rw, format, *invalidParameterError("id"))
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		WriteError(rw, format, NewError(ERROR_MISSING_PARAMETER, "missing parameter 'id'"))
+		return
+	}
+
+	statuses, err := handler.Submissions.GetSubmissionStatuses(r.Context(), ids)
+	if err != nil {
+		log.Printf("Failed to load submission statuses: %v", err)
+		WriteError(rw, format, NewError(ERROR_INTERNAL, "internal error"))
+		return
+	}
+
+	response := SubmissionStatusResponse{Status: "ok", Submissions: []SubmissionStatusResult{}}
+	for _, status := range statuses {
+		result := SubmissionStatusResult{ID: status.ID, Status: "pending"}
+		if status.Handled {
+			result.Status = "imported"
+			if status.TrackGID != "" {
+				result.Response = &SubmissionStatusTrack{ID: status.TrackGID}
+			}
+		}
+		response.Submissions = append(response.Submissions, result)
+	}
+
+	err = WriteResponse(rw, http.StatusOK, format, response)
+	if err != nil {
+		log.Printf("Failed to write response: %v", err)
+		WriteError(rw, format, NewError(ERROR_INTERNAL, "internal error"))
+		return
+	}
+}
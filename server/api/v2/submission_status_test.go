@@ -0,0 +1,77 @@
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/acoustid/go-acoustid/server/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSubmissionStatusHandler() http.Handler {
+	return NewSubmissionStatusHandler(&mockSubmissionService{
+		statuses: []services.SubmissionStatus{
+			{ID: 1, Handled: false},
+			{ID: 2, Handled: true, TrackGID: "b81f83ee-4da4-11e0-9ed8-0025225356f3"},
+		},
+	})
+}
+
+func TestSubmissionStatusHandler(t *testing.T) {
+	handler := newTestSubmissionStatusHandler()
+
+	req := httptest.NewRequest("GET", "/v2/submission_status?id=1&id=2&id=3", nil)
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var response SubmissionStatusResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	assert.Equal(t, "ok", response.Status)
+	require.Len(t, response.Submissions, 2)
+	assert.Equal(t, SubmissionStatusResult{ID: 1, Status: "pending"}, response.Submissions[0])
+	assert.Equal(t, SubmissionStatusResult{ID: 2, Status: "imported", Response: &SubmissionStatusTrack{ID: "b81f83ee-4da4-11e0-9ed8-0025225356f3"}}, response.Submissions[1])
+}
+
+func TestSubmissionStatusHandler_XML(t *testing.T) {
+	handler := newTestSubmissionStatusHandler()
+
+	req := httptest.NewRequest("GET", "/v2/submission_status?id=2&format=xml", nil)
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Contains(t, rw.Body.String(), "<submissions><submission><id>2</id><status>imported</status><response><id>b81f83ee-4da4-11e0-9ed8-0025225356f3</id></response></submission></submissions>")
+}
+
+func TestSubmissionStatusHandler_InvalidID(t *testing.T) {
+	handler := newTestSubmissionStatusHandler()
+
+	for _, query := range []string{"", "id=x"} {
+		req := httptest.NewRequest("GET", "/v2/submission_status?"+query, nil)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		assert.Equal(t, http.StatusBadRequest, rw.Code, query)
+	}
+}
+
+func TestSubmissionStatusHandler_TooManyIDs(t *testing.T) {
+	handler := newTestSubmissionStatusHandler()
+
+	query := url.Values{}
+	for i := 1; i <= MaxBatchSize+1; i++ {
+		query.Add("id", strconv.Itoa(i))
+	}
+	req := httptest.NewRequest("GET", "/v2/submission_status?"+query.Encode(), nil)
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	assert.Equal(t, ERROR_TOO_MANY_ITEMS, response.Error.Code)
+}
@@ -0,0 +1,237 @@
+package v2
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/acoustid/go-acoustid/chromaprint"
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+type SubmitResult struct {
+	Index   int           `json:"index" xml:"index"`
+	ID      int           `json:"id,omitempty" xml:"id,omitempty"`
+	Status  string        `json:"status" xml:"status"`
+	Error   *ErrorDetails `json:"error,omitempty" xml:"error,omitempty"`
+	XMLName struct{}      `json:"-" xml:"submission"`
+}
+
+type SubmitResponse struct {
+	Status      string         `json:"status" xml:"status"`
+	Submissions []SubmitResult `json:"submissions" xml:"submissions>submission"`
+	XMLName     struct{}       `json:"-" xml:"response"`
+}
+
+type SubmitHandler struct {
+	Accounts    services.AccountService
+	Submissions services.SubmissionService
+}
+
+func NewSubmitHandler(accounts services.AccountService, submissions services.SubmissionService) http.Handler {
+	return &SubmitHandler{Accounts: accounts, Submissions: submissions}
+}
+
+func missingParameterError(name string) *Error {
+	e := NewError(ERROR_MISSING_PARAMETER, fmt.Sprintf("missing parameter '%s'", name))
+	return &e
+}
+
+func tooManyItemsError() *Error {
+	e := NewError(ERROR_TOO_MANY_ITEMS, fmt.Sprintf("too many items, at most %d are allowed", MaxBatchSize))
+	return &e
+}
+
+// invalidParameterError is used for parameters that don't have a more specific error code.
+func invalidParameterError(name string) *Error {
+	e := NewError(ERROR_INVALID_PARAMETER, fmt.Sprintf("invalid parameter '%s'", name))
+	return &e
+}
+
+func parseSubmission(item ItemParams) ([]services.Submission, *Error) {
+	var submission services.Submission
+
+	durationStr := item.Get("duration")
+	if durationStr == "" {
+		return nil, missingParameterError(item.Name("duration"))
+	}
+	duration, err := ParseDuration(durationStr)
+	if err != nil {
+		log.Printf("Invalid duration: %s", err)
+		e := NewError(ERROR_INVALID_DURATION, "invalid duration")
+		return nil, &e
+	}
+	submission.Duration = duration
+
+	fingerprintStr := item.Get("fingerprint")
+	if fingerprintStr == "" {
+		return nil, missingParameterError(item.Name("fingerprint"))
+	}
+	submission.Fingerprint, err = chromaprint.ParseFingerprintString(fingerprintStr)
+	if err != nil {
+		log.Printf("Invalid fingerprint: %s", err)
+		e := NewError(ERROR_INVALID_FINGERPRINT, "invalid fingerprint")
+		return nil, &e
+	}
+
+	bitrateStr := item.Get("bitrate")
+	if bitrateStr != "" {
+		bitrate, err := strconv.Atoi(bitrateStr)
+		if err != nil || bitrate <= 0 || bitrate > 32767 {
+			e := NewError(ERROR_INVALID_BITRATE, "invalid bitrate")
+			return nil, &e
+		}
+		submission.Bitrate = bitrate
+	}
+
+	submission.Format = item.Get("fileformat")
+
+	puid := item.Get("puid")
+	if puid != "" {
+		if !IsValidUUID(puid) {
+			e := NewError(ERROR_INVALID_UUID, "invalid UUID")
+			return nil, &e
+		}
+		submission.PUID = puid
+	}
+
+	foreignIDStr := item.Get("foreignid")
+	if foreignIDStr != "" {
+		foreignID, err := ParseForeignID(foreignIDStr)
+		if err != nil {
+			e := NewError(ERROR_INVALID_FOREIGNID, "invalid foreign ID")
+			return nil, &e
+		}
+		submission.ForeignID = &foreignID
+	}
+
+	meta := services.SubmissionMeta{
+		Track:       item.Get("track"),
+		Artist:      item.Get("artist"),
+		Album:       item.Get("album"),
+		AlbumArtist: item.Get("albumartist"),
+	}
+	meta.TrackNo, _ = strconv.Atoi(item.Get("trackno"))
+	meta.DiscNo, _ = strconv.Atoi(item.Get("discno"))
+	meta.Year, _ = strconv.Atoi(item.Get("year"))
+	if meta != (services.SubmissionMeta{}) {
+		submission.Meta = &meta
+	}
+
+	var mbids []string
+	seen := make(map[string]bool)
+	for _, mbid := range item.GetAll("mbid") {
+		if mbid == "" || seen[mbid] {
+			continue
+		}
+		if !IsValidUUID(mbid) {
+			e := NewError(ERROR_INVALID_UUID, "invalid UUID")
+			return nil, &e
+		}
+		seen[mbid] = true
+		mbids = append(mbids, mbid)
+	}
+
+	if len(mbids) == 0 {
+		return []services.Submission{submission}, nil
+	}
+	submissions := make([]services.Submission, len(mbids))
+	for i, mbid := range mbids {
+		submissions[i] = submission
+		submissions[i].MBID = mbid
+	}
+	return submissions, nil
+}
+
+func (handler *SubmitHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	format, err := GetResponseFormat(r)
+	if err != nil {
+		WriteError(rw, DefaultFormat, NewError(ERROR_INVALID_FORMAT, "invalid format"))
+		return
+	}
+
+	ctx := r.Context()
+
+	app := ApplicationFromContext(ctx)
+	if app == nil {
+		log.Printf("Submit handler called without an authenticated application")
+		WriteError(rw, format, NewError(ERROR_INTERNAL, "internal error"))
+		return
+	}
+
+	userAPIKey := r.FormValue("user")
+	if userAPIKey == "" {
+		WriteError(rw, format, NewError(ERROR_MISSING_PARAMETER, "missing parameter 'user'"))
+		return
+	}
+	account, err := handler.Accounts.GetAccountByAPIKey(ctx, userAPIKey)
+	if err != nil {
+		log.Printf("Failed to load account: %v", err)
+		WriteError(rw, format, NewError(ERROR_INTERNAL, "internal error"))
+		return
+	}
+	if account == nil {
+		WriteError(rw, format, NewError(ERROR_INVALID_USER_APIKEY, "invalid user API key"))
+		return
+	}
+
+	items, err := SplitItemParams(r.Form, "fingerprint")
+	if err != nil {
+		WriteError(rw, format, *tooManyItemsError())
+		return
+	}
+	if len(items) == 0 {
+		WriteError(rw, format, NewError(ERROR_MISSING_PARAMETER, "missing parameter 'fingerprint'"))
+		return
+	}
+
+	response := SubmitResponse{Status: "ok"}
+	var submissions []services.Submission
+	var positions []int
+	var firstError *Error
+	for _, item := range items {
+		itemSubmissions, e := parseSubmission(item)
+		if e != nil {
+			if firstError == nil {
+				firstError = e
+			}
+			response.Submissions = append(response.Submissions, SubmitResult{
+				Index:  item.Index,
+				Status: "error",
+				Error:  &e.ErrorDetails,
+			})
+			continue
+		}
+		for _, submission := range itemSubmissions {
+			positions = append(positions, len(response.Submissions))
+			response.Submissions = append(response.Submissions, SubmitResult{
+				Index:  item.Index,
+				Status: "pending",
+			})
+			submissions = append(submissions, submission)
+		}
+	}
+
+	if len(submissions) == 0 {
+		WriteError(rw, format, *firstError)
+		return
+	}
+
+	ids, err := handler.Submissions.Submit(ctx, app, account, r.FormValue("clientversion"), submissions)
+	if err != nil {
+		log.Printf("Failed to submit: %v", err)
+		WriteError(rw, format, NewError(ERROR_INTERNAL, "internal error"))
+		return
+	}
+	for i, id := range ids {
+		response.Submissions[positions[i]].ID = id
+	}
+
+	err = WriteResponse(rw, http.StatusOK, format, response)
+	if err != nil {
+		log.Printf("Failed to write response: %v", err)
+		WriteError(rw, format, NewError(ERROR_INTERNAL, "internal error"))
+		return
+	}
+}
@@ -0,0 +1,148 @@
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/acoustid/go-acoustid/server/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAccountService struct{}
+
+func (s *mockAccountService) GetAccountByAPIKey(ctx context.Context, apiKey string) (*services.Account, error) {
+	if apiKey == "user" {
+		return &services.Account{ID: 10}, nil
+	}
+	return nil, nil
+}
+
+type mockSubmissionService struct {
+	submissions   []services.Submission
+	clientVersion string
+	statuses      []services.SubmissionStatus
+}
+
+func (s *mockSubmissionService) Submit(ctx context.Context, app *services.Application, account *services.Account, clientVersion string, submissions []services.Submission) ([]int, error) {
+	s.submissions = submissions
+	s.clientVersion = clientVersion
+	ids := make([]int, len(submissions))
+	for i := range submissions {
+		ids[i] = 100 + i
+	}
+	return ids, nil
+}
+
+func (s *mockSubmissionService) GetSubmissionStatuses(ctx context.Context, ids []int) ([]services.SubmissionStatus, error) {
+	var statuses []services.SubmissionStatus
+	for _, id := range ids {
+		for _, status := range s.statuses {
+			if status.ID == id {
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	return statuses, nil
+}
+
+func doSubmit(t *testing.T, handler http.Handler, params url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/v2/submit", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(WithApplication(req.Context(), &services.Application{ID: 1, Active: true}))
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	return rw
+}
+
+func TestSubmitHandler_Single(t *testing.T) {
+	submissions := &mockSubmissionService{}
+	handler := NewSubmitHandler(&mockAccountService{}, submissions)
+
+	rw := doSubmit(t, handler, url.Values{
+		"user":          {"user"},
+		"clientversion": {"1.0"},
+		"duration":      {"215"},
+		"fingerprint":   {testFingerprint},
+		"bitrate":       {"192"},
+		"fileformat":    {"MP3"},
+		"track":         {"Sunrise"},
+		"trackno":       {"3"},
+		"foreignid":     {"spotify:track:123"},
+	})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var response SubmitResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	require.Len(t, response.Submissions, 1)
+	assert.Equal(t, 100, response.Submissions[0].ID)
+	assert.Equal(t, "pending", response.Submissions[0].Status)
+
+	require.Len(t, submissions.submissions, 1)
+	submission := submissions.submissions[0]
+	assert.Equal(t, "1.0", submissions.clientVersion)
+	assert.Equal(t, 192, submission.Bitrate)
+	assert.Equal(t, "MP3", submission.Format)
+	assert.Equal(t, &services.SubmissionMeta{Track: "Sunrise", TrackNo: 3}, submission.Meta)
+	assert.Equal(t, &services.ForeignID{Vendor: "spotify", Name: "track:123"}, submission.ForeignID)
+}
+
+func TestSubmitHandler_Multiple(t *testing.T) {
+	submissions := &mockSubmissionService{}
+	handler := NewSubmitHandler(&mockAccountService{}, submissions)
+
+	rw := doSubmit(t, handler, url.Values{
+		"user":          {"user"},
+		"duration.0":    {"215"},
+		"fingerprint.0": {testFingerprint},
+		"mbid.0":        {"b8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f", "c8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f"},
+		"track.0":       {"Sunrise"},
+		"duration.1":    {"100"},
+		"fingerprint.1": {"xxx"},
+		"duration.2":    {"100"},
+		"fingerprint.2": {testFingerprint},
+		"format":        {"xml"},
+	})
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	body := rw.Body.String()
+	assert.Contains(t, body, "<submission><index>0</index><id>100</id><status>pending</status></submission>")
+	assert.Contains(t, body, "<submission><index>0</index><id>101</id><status>pending</status></submission>")
+	assert.Contains(t, body, "<submission><index>1</index><status>error</status><error><code>3</code>")
+	assert.Contains(t, body, "<submission><index>2</index><id>102</id><status>pending</status></submission>")
+
+	require.Len(t, submissions.submissions, 3)
+	assert.Equal(t, "b8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f", submissions.submissions[0].MBID)
+	assert.Equal(t, "c8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f", submissions.submissions[1].MBID)
+	require.NotNil(t, submissions.submissions[0].Meta)
+	assert.True(t, submissions.submissions[0].Meta == submissions.submissions[1].Meta, "submissions of one item should share the meta")
+	assert.Nil(t, submissions.submissions[2].Meta)
+}
+
+func TestSubmitHandler_Errors(t *testing.T) {
+	handler := NewSubmitHandler(&mockAccountService{}, &mockSubmissionService{})
+
+	tests := []struct {
+		params url.Values
+		code   int
+	}{
+		{url.Values{"duration": {"215"}, "fingerprint": {testFingerprint}}, ERROR_MISSING_PARAMETER},
+		{url.Values{"user": {"x"}, "duration": {"215"}, "fingerprint": {testFingerprint}}, ERROR_INVALID_USER_APIKEY},
+		{url.Values{"user": {"user"}}, ERROR_MISSING_PARAMETER},
+		{url.Values{"user": {"user"}, "duration": {"215"}, "fingerprint": {testFingerprint}, "bitrate": {"x"}}, ERROR_INVALID_BITRATE},
+		{url.Values{"user": {"user"}, "duration": {"215"}, "fingerprint": {testFingerprint}, "mbid": {"x"}}, ERROR_INVALID_UUID},
+		{url.Values{"user": {"user"}, "duration": {"215"}, "fingerprint": {testFingerprint}, "foreignid": {"x"}}, ERROR_INVALID_FOREIGNID},
+		{url.Values{"user": {"user"}, "duration": {"0"}, "fingerprint": {testFingerprint}}, ERROR_INVALID_DURATION},
+	}
+	for _, test := range tests {
+		rw := doSubmit(t, handler, test.params)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		var response ErrorResponse
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+		assert.Equal(t, test.code, response.Error.Code, "params=%v", test.params)
+	}
+}
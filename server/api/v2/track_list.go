@@ -0,0 +1,179 @@
+package v2
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+type TrackListTrack struct {
+	ID       string `json:"id" xml:"id"`
+	Disabled bool   `json:"disabled,omitempty" xml:"disabled,omitempty"`
+}
+
+type TrackListResponse struct {
+	Status  string           `json:"status" xml:"status"`
+	Tracks  []TrackListTrack `json:"tracks" xml:"tracks>track"`
+	XMLName struct{}         `json:"-" xml:"response"`
+}
+
+type TrackListItem struct {
+	MBID      string           `json:"mbid,omitempty" xml:"mbid,omitempty"`
+	PUID      string           `json:"puid,omitempty" xml:"puid,omitempty"`
+	ForeignID string           `json:"foreignid,omitempty" xml:"foreignid,omitempty"`
+	Tracks    []TrackListTrack `json:"tracks" xml:"tracks>track"`
+}
+
+type BatchTrackListResponse struct {
+	Status     string          `json:"status" xml:"status"`
+	MBIDs      []TrackListItem `json:"mbids,omitempty" xml:"mbids>mbid,omitempty"`
+	PUIDs      []TrackListItem `json:"puids,omitempty" xml:"puids>puid,omitempty"`
+	ForeignIDs []TrackListItem `json:"foreignids,omitempty" xml:"foreignids>foreignid,omitempty"`
+	XMLName    struct{}        `json:"-" xml:"response"`
+}
+
+// TrackListHandler lists tracks linked to external identifiers, given in one or more values of the Param parameter.
+type TrackListHandler struct {
+	Param string
+	// parse validates and normalizes one value of the parameter.
+	parse func(value string) (string, *Error)
+	// list loads the linked tracks, keyed by the normalized values.
+	list func(ctx context.Context, keys []string, r *http.Request) (map[string][]services.LinkedTrack, error)
+	// batchResponse builds the response for requests with multiple values.
+	batchResponse func(items []TrackListItem, keys []string) BatchTrackListResponse
+}
+
+func parseUUIDParam(value string) (string, *Error) {
+	if !IsValidUUID(value) {
+		e := NewError(ERROR_INVALID_UUID, "invalid UUID")
+		return "", &e
+	}
+	return strings.ToLower(value), nil
+}
+
+// NewTrackListByMBIDHandler returns a handler listing tracks linked to MusicBrainz recording IDs.
+// Links disabled by moderators are only included if the 'disabled' parameter is set.
+func NewTrackListByMBIDHandler(tracks services.TrackService) http.Handler {
+	return &TrackListHandler{
+		Param: "mbid",
+		parse: parseUUIDParam,
+		list: func(ctx context.Context, keys []string, r *http.Request) (map[string][]services.LinkedTrack, error) {
+			includeDisabled := r.FormValue("disabled") == "1"
+			return tracks.GetTracksByMBIDs(ctx, keys, includeDisabled)
+		},
+		batchResponse: func(items []TrackListItem, keys []string) BatchTrackListResponse {
+			for i := range items {
+				items[i].MBID = keys[i]
+			}
+			return BatchTrackListResponse{Status: "ok", MBIDs: items}
+		},
+	}
+}
+
+// NewTrackListByPUIDHandler returns a handler listing tracks linked to MusicDNS PUIDs.
+func NewTrackListByPUIDHandler(tracks services.TrackService) http.Handler {
+	return &TrackListHandler{
+		Param: "puid",
+		parse: parseUUIDParam,
+		list: func(ctx context.Context, keys []string, r *http.Request) (map[string][]services.LinkedTrack, error) {
+			return tracks.GetTracksByPUIDs(ctx, keys)
+		},
+		batchResponse: func(items []TrackListItem, keys []string) BatchTrackListResponse {
+			for i := range items {
+				items[i].PUID = keys[i]
+			}
+			return BatchTrackListResponse{Status: "ok", PUIDs: items}
+		},
+	}
+}
+
+// NewTrackListByForeignIDHandler returns a handler listing tracks linked to foreign IDs in the "vendor:name" format.
+func NewTrackListByForeignIDHandler(tracks services.TrackService) http.Handler {
+	return &TrackListHandler{
+		Param: "foreignid",
+		parse: func(value string) (string, *Error) {
+			foreignID, err := ParseForeignID(value)
+			if err != nil {
+				e := NewError(ERROR_INVALID_FOREIGNID, "invalid foreign ID")
+				return "", &e
+			}
+			return foreignID.String(), nil
+		},
+		list: func(ctx context.Context, keys []string, r *http.Request) (map[string][]services.LinkedTrack, error) {
+			foreignIDs := make([]services.ForeignID, len(keys))
+			for i, key := range keys {
+				foreignIDs[i], _ = ParseForeignID(key)
+			}
+			return tracks.GetTracksByForeignIDs(ctx, foreignIDs)
+		},
+		batchResponse: func(items []TrackListItem, keys []string) BatchTrackListResponse {
+			for i := range items {
+				items[i].ForeignID = keys[i]
+			}
+			return BatchTrackListResponse{Status: "ok", ForeignIDs: items}
+		},
+	}
+}
+
+func convertLinkedTracks(tracks []services.LinkedTrack) []TrackListTrack {
+	converted := make([]TrackListTrack, len(tracks))
+	for i, track := range tracks {
+		converted[i] = TrackListTrack{ID: track.GID, Disabled: track.Disabled}
+	}
+	return converted
+}
+
+func (handler *TrackListHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	format, err := GetResponseFormat(r)
+	if err != nil {
+		WriteError(rw, DefaultFormat, NewError(ERROR_INVALID_FORMAT, "invalid format"))
+		return
+	}
+
+	if len(r.Form[handler.Param]) > MaxBatchSize {
+		WriteError(rw, format, *tooManyItemsError())
+		return
+	}
+
+	var keys []string
+	for _, value := range r.Form[handler.Param] {
+		key, e := handler.parse(value)
+		if e != nil {
+			WriteError(rw, format, *e)
+			return
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		WriteError(rw, format, *missingParameterError(handler.Param))
+		return
+	}
+
+	tracks, err := handler.list(r.Context(), keys, r)
+	if err != nil {
+		log.Printf("Failed to list tracks by %s: %v", handler.Param, err)
+		WriteError(rw, format, NewError(ERROR_INTERNAL, "internal error"))
+		return
+	}
+
+	var response interface{}
+	if len(keys) > 1 || r.FormValue("batch") == "1" {
+		items := make([]TrackListItem, len(keys))
+		for i, key := range keys {
+			items[i].Tracks = convertLinkedTracks(tracks[key])
+		}
+		response = handler.batchResponse(items, keys)
+	} else {
+		response = TrackListResponse{Status: "ok", Tracks: convertLinkedTracks(tracks[keys[0]])}
+	}
+
+	err = WriteResponse(rw, http.StatusOK, format, response)
+	if err != nil {
+		log.Printf("Failed to write response: %v", err)
+		WriteError(rw, format, NewError(ERROR_INTERNAL, "internal error"))
+		return
+	}
+}
@@ -0,0 +1,143 @@
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/acoustid/go-acoustid/server/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testMBID1 = "b8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f"
+	testMBID2 = "c8f3a1e4-6f4a-4a0b-8f0e-0f6b5c6d7e8f"
+)
+
+func newTestTrackService() *mockTrackService {
+	return &mockTrackService{
+		mbids: map[string][]services.LinkedTrack{
+			testMBID1: {
+				{GID: "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e"},
+				{GID: "0f4e4b1c-3b5a-4c2d-8e1f-6a7b8c9d0e1f", Disabled: true},
+			},
+		},
+		puids: map[string][]services.LinkedTrack{
+			testMBID2: {{GID: "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e"}},
+		},
+		foreignIDs: map[string][]services.LinkedTrack{
+			"spotify:track:123": {{GID: "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e"}},
+		},
+	}
+}
+
+func doTrackList(handler http.Handler, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/v2/track/list?"+query, nil)
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	return rw
+}
+
+func TestTrackListByMBIDHandler(t *testing.T) {
+	tracks := newTestTrackService()
+	handler := NewTrackListByMBIDHandler(tracks)
+
+	rw := doTrackList(handler, "mbid="+testMBID1+"&disabled=1")
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.True(t, tracks.includeDisabled)
+
+	var response TrackListResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	assert.Equal(t, []TrackListTrack{
+		{ID: "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e"},
+		{ID: "0f4e4b1c-3b5a-4c2d-8e1f-6a7b8c9d0e1f", Disabled: true},
+	}, response.Tracks)
+}
+
+func TestTrackListByMBIDHandler_Batch(t *testing.T) {
+	handler := NewTrackListByMBIDHandler(newTestTrackService())
+
+	rw := doTrackList(handler, "mbid="+testMBID1+"&mbid="+testMBID2)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var response BatchTrackListResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	require.Len(t, response.MBIDs, 2)
+	assert.Equal(t, testMBID1, response.MBIDs[0].MBID)
+	assert.Len(t, response.MBIDs[0].Tracks, 2)
+	assert.Equal(t, testMBID2, response.MBIDs[1].MBID)
+	assert.Empty(t, response.MBIDs[1].Tracks)
+	assert.Empty(t, response.PUIDs)
+}
+
+func TestTrackListByMBIDHandler_XML(t *testing.T) {
+	handler := NewTrackListByMBIDHandler(newTestTrackService())
+
+	rw := doTrackList(handler, "mbid="+testMBID1+"&batch=1&format=xml")
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Contains(t, rw.Body.String(), "<mbids><mbid><mbid>"+testMBID1+"</mbid><tracks><track><id>a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e</id></track>")
+}
+
+func TestTrackListByMBIDHandler_InvalidMBID(t *testing.T) {
+	handler := NewTrackListByMBIDHandler(newTestTrackService())
+
+	rw := doTrackList(handler, "mbid=foo")
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"code":7`)
+
+	rw = doTrackList(handler, "")
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), "missing parameter 'mbid'")
+}
+
+func TestTrackListByMBIDHandler_TooManyMBIDs(t *testing.T) {
+	handler := NewTrackListByMBIDHandler(newTestTrackService())
+
+	query := url.Values{}
+	for i := 0; i <= MaxBatchSize; i++ {
+		query.Add("mbid", testMBID1)
+	}
+	rw := doTrackList(handler, query.Encode())
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	assert.Equal(t, ERROR_TOO_MANY_ITEMS, response.Error.Code)
+}
+
+func TestTrackListByPUIDHandler(t *testing.T) {
+	handler := NewTrackListByPUIDHandler(newTestTrackService())
+
+	rw := doTrackList(handler, "puid="+testMBID2)
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var response TrackListResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	assert.Equal(t, []TrackListTrack{{ID: "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e"}}, response.Tracks)
+}
+
+func TestTrackListByForeignIDHandler(t *testing.T) {
+	handler := NewTrackListByForeignIDHandler(newTestTrackService())
+
+	rw := doTrackList(handler, "foreignid=spotify:track:123&foreignid=spotify:track:456")
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+
+	var response BatchTrackListResponse
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &response))
+	require.Len(t, response.ForeignIDs, 2)
+	assert.Equal(t, "spotify:track:123", response.ForeignIDs[0].ForeignID)
+	assert.Equal(t, []TrackListTrack{{ID: "a7d5d7a5-9f1c-4b5e-9a5d-2c1b0a2f7d6e"}}, response.ForeignIDs[0].Tracks)
+	assert.Equal(t, "spotify:track:456", response.ForeignIDs[1].ForeignID)
+	assert.Empty(t, response.ForeignIDs[1].Tracks)
+}
+
+func TestTrackListByForeignIDHandler_Invalid(t *testing.T) {
+	handler := NewTrackListByForeignIDHandler(newTestTrackService())
+
+	rw := doTrackList(handler, "foreignid=Spotify")
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"code":10`)
+}
@@ -1,17 +1,29 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/acoustid/go-acoustid/database/app_db"
 	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+	"github.com/acoustid/go-acoustid/database/ingest_db"
+	"github.com/acoustid/go-acoustid/database/musicbrainz_db"
 	"github.com/acoustid/go-acoustid/index"
 	"github.com/acoustid/go-acoustid/server/api"
+	v2 "github.com/acoustid/go-acoustid/server/api/v2"
+	"github.com/acoustid/go-acoustid/server/services"
 	"github.com/acoustid/go-acoustid/server/services/legacy"
+	"github.com/go-redis/redis/v8"
 	_ "github.com/lib/pq"
 	"github.com/urfave/cli"
 )
@@ -23,7 +35,7 @@ var DebugFlag = cli.BoolFlag{
 }
 
 func RunApiCommand(c *cli.Context) error {
-	api := api.NewAPI()
+	ws := api.NewAPI()
 
 	indexAddr := c.String("index-address")
 	host, portStr, err := net.SplitHostPort(indexAddr)
@@ -37,7 +49,44 @@ func RunApiCommand(c *cli.Context) error {
 	indexConfig := index.NewIndexConfig()
 	indexConfig.Host = host
 	indexConfig.Port = port
-	indexClientPool := index.NewIndexClientPool(indexConfig, 100)
+	for _, shard := range c.StringSlice("index-shard") {
+		shardConfig, err := index.ParseShardConfig(shard)
+		if err != nil {
+			return fmt.Errorf("failed to parse index-shard: %w", err)
+		}
+		indexConfig.Shards = append(indexConfig.Shards, shardConfig)
+	}
+	for _, replica := range c.StringSlice("index-replica") {
+		_, _, err := index.ParseAddr(replica)
+		if err != nil {
+			return fmt.Errorf("failed to parse index-replica: %w", err)
+		}
+		indexConfig.Replicas = append(indexConfig.Replicas, replica)
+	}
+	indexConfig.ReplicaSelection = c.String("index-replica-selection")
+	if indexConfig.ReplicaSelection != index.SelectRoundRobin && indexConfig.ReplicaSelection != index.SelectLeastLoaded {
+		return fmt.Errorf("invalid index-replica-selection %q", indexConfig.ReplicaSelection)
+	}
+	indexConfig.HedgePercentile = c.Float64("index-hedge-percentile")
+	if indexConfig.HedgePercentile < 0 || indexConfig.HedgePercentile > 100 {
+		return fmt.Errorf("invalid index-hedge-percentile %v", indexConfig.HedgePercentile)
+	}
+	indexConfig.PipelinedConnections = c.Int("index-pipelined-connections")
+	indexConfig.Partitioning = c.String("index-partitioning")
+	if indexConfig.Partitioning != index.PartitionByID && indexConfig.Partitioning != index.PartitionByHash {
+		return fmt.Errorf("invalid index-partitioning %q", indexConfig.Partitioning)
+	}
+
+	var indexSearcher legacy.IndexSearcher
+	if len(indexConfig.Shards) > 0 {
+		shardedSearcher := index.NewShardedSearcher(indexConfig, 100)
+		shardedSearcher.Timeout = c.Duration("index-shard-timeout")
+		indexSearcher = shardedSearcher
+	} else if len(indexConfig.Replicas) > 0 {
+		indexSearcher = index.NewReplicaPool(indexConfig, 100)
+	} else {
+		indexSearcher = index.NewSearcherPool(indexConfig, 100)
+	}
 
 	db, err := sql.Open("postgres", c.String("fingerprint-db-url"))
 	if err != nil {
@@ -47,8 +96,145 @@ func RunApiCommand(c *cli.Context) error {
 
 	fingerprintDB := fingerprint_db.NewFingerprintDB(db)
 
-	api.FingerprintSearcher = legacy.NewFingerprintSearcher(indexClientPool, fingerprintDB)
-	return api.ListenAndServe(c.String("listen"))
+	musicBrainzDB, err := sql.Open("postgres", c.String("musicbrainz-db-url"))
+	if err != nil {
+		return fmt.Errorf("failed to connect to musicbrainz database: %w", err)
+	}
+	defer musicBrainzDB.Close()
+
+	appConn, err := sql.Open("postgres", c.String("app-db-url"))
+	if err != nil {
+		return fmt.Errorf("failed to connect to app database: %w", err)
+	}
+	defer appConn.Close()
+
+	appDB := app_db.NewAppDB(appConn)
+
+	ingestDB, err := sql.Open("postgres", c.String("ingest-db-url"))
+	if err != nil {
+		return fmt.Errorf("failed to connect to ingest database: %w", err)
+	}
+	defer ingestDB.Close()
+
+	fingerprintSearcher := legacy.NewFingerprintSearcher(indexSearcher, fingerprintDB)
+	if c.Bool("local-scoring") {
+		var cache *legacy.FingerprintCache
+		cacheSize := c.Int("fingerprint-cache-size")
+		if cacheSize > 0 {
+			cache = legacy.NewFingerprintCache(cacheSize * 1024 * 1024)
+			cache.CandidateTTL = c.Duration("fingerprint-cache-metadata-ttl")
+		}
+		fingerprintSearcher.Scorer = legacy.NewLocalMatchScorer(fingerprintDB, cache)
+	}
+	ws.FingerprintSearcher = fingerprintSearcher
+	if searchCacheSize := c.Int("search-cache-size"); searchCacheSize > 0 {
+		ws.FingerprintSearcher = services.NewCachedFingerprintSearcher(fingerprintSearcher, c.Duration("search-cache-ttl"), searchCacheSize)
+	}
+	ws.SearchConfig = createSearchConfig(c)
+	ws.TrackService = legacy.NewTrackService(fingerprintDB)
+	ws.MetadataService = legacy.NewMetadataService(fingerprintDB, musicbrainz_db.NewMusicBrainzDB(musicBrainzDB))
+	ws.ApplicationService = services.NewCachedApplicationService(
+		legacy.NewApplicationService(appDB),
+		c.Duration("application-cache-ttl"),
+		c.Int("application-cache-size"))
+	ws.AccountService = legacy.NewAccountService(appDB)
+	ws.SubmissionService = legacy.NewSubmissionService(appDB, fingerprintDB, ingest_db.NewIngestDB(ingestDB))
+
+	rateLimiter, err := createRateLimiter(c)
+	if err != nil {
+		return err
+	}
+	ws.RateLimiter = rateLimiter
+
+	statsCollector := api.NewStatsCollector(legacy.NewStatsService(appDB), c.Duration("stats-flush-interval"))
+	statsCollector.Start()
+	defer func() {
+		err := statsCollector.Close()
+		if err != nil {
+			log.Printf("Failed to flush lookup stats: %v", err)
+		}
+	}()
+	ws.StatsCollector = statsCollector
+
+	return listenAndServe(c.String("listen"), ws)
+}
+
+// listenAndServe runs the HTTP server until SIGINT or SIGTERM is received,
+// and then waits for active requests to finish.
+func listenAndServe(addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+		log.Printf("Shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := server.Shutdown(ctx)
+		if err != nil {
+			log.Printf("Failed to shut down gracefully: %v", err)
+		}
+	}()
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		<-shutdownDone
+		return nil
+	}
+	return err
+}
+
+func createSearchConfig(c *cli.Context) *v2.SearchConfig {
+	cfg := v2.NewSearchConfig()
+	cfg.Defaults.MaxDurationDiff = c.Duration("search-max-duration-diff")
+	cfg.Defaults.MaxResults = c.Int("search-max-results")
+	cfg.Defaults.MinScore = c.Float64("search-min-score")
+	cfg.Defaults.MaxCandidates = c.Int("search-max-candidates")
+	cfg.Defaults.MinHits = c.Int("search-min-hits")
+	cfg.Defaults.MinHitsPercent = c.Int("search-min-hits-percent")
+	cfg.MaxDurationDiff = c.Duration("search-max-duration-diff-limit")
+	cfg.MaxResults = c.Int("search-max-results-limit")
+	cfg.MaxCandidates = c.Int("search-max-candidates-limit")
+	return cfg
+}
+
+func createRateLimiter(c *cli.Context) (*api.RateLimiter, error) {
+	var err error
+	cfg := api.NewRateLimiterConfig()
+	cfg.IP, err = api.ParseRateLimit(c.String("rate-limit-ip"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rate-limit-ip: %w", err)
+	}
+	cfg.Application, err = api.ParseRateLimit(c.String("rate-limit-app"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rate-limit-app: %w", err)
+	}
+	for _, override := range c.StringSlice("rate-limit-app-override") {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid rate-limit-app-override %q, expected apikey=rate[:burst]", override)
+		}
+		limit, err := api.ParseRateLimit(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rate-limit-app-override: %w", err)
+		}
+		cfg.ApplicationOverrides[parts[0]] = limit
+	}
+	cfg.RealIPHeader = c.String("real-ip-header")
+	cfg.TrustedProxies, err = api.ParseTrustedProxies(c.StringSlice("trusted-proxy"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted-proxy: %w", err)
+	}
+
+	var store api.RateLimitStore
+	redisAddr := c.String("redis-address")
+	if redisAddr != "" {
+		store = api.NewRedisRateLimitStore(redis.NewClient(&redis.Options{Addr: redisAddr}))
+	} else {
+		store = api.NewMemoryRateLimitStore()
+	}
+	return api.NewRateLimiter(store, cfg), nil
 }
 
 var ApiCommand = cli.Command{
@@ -68,12 +254,197 @@ var ApiCommand = cli.Command{
 			EnvVar: "ACOUSTID_API_INDEX_ADDRESS",
 			Value:  "127.0.0.1:6080",
 		},
+		cli.StringSliceFlag{
+			Name:   "index-replica",
+			Usage:  "address of another index server with the same data as index-address, in the host:port format",
+			EnvVar: "ACOUSTID_API_INDEX_REPLICA",
+		},
+		cli.StringFlag{
+			Name:   "index-replica-selection",
+			Usage:  "how to choose index replicas, either \"round-robin\" or \"least-loaded\"",
+			EnvVar: "ACOUSTID_API_INDEX_REPLICA_SELECTION",
+			Value:  index.SelectRoundRobin,
+		},
+		cli.Float64Flag{
+			Name:   "index-hedge-percentile",
+			Usage:  "send a search to another index replica if it takes longer than this percentile of recent search latencies, 0 disables it",
+			EnvVar: "ACOUSTID_API_INDEX_HEDGE_PERCENTILE",
+		},
+		cli.IntFlag{
+			Name:   "index-pipelined-connections",
+			Usage:  "number of connections to each index server shared by concurrent searches, 0 uses one connection per search",
+			EnvVar: "ACOUSTID_API_INDEX_PIPELINED_CONNECTIONS",
+		},
+		cli.StringSliceFlag{
+			Name:   "index-shard",
+			Usage:  "address of one shard of a sharded index, in the host:port or host:port=minid-maxid format, overrides index-address",
+			EnvVar: "ACOUSTID_API_INDEX_SHARD",
+		},
+		cli.StringFlag{
+			Name:   "index-partitioning",
+			Usage:  "how the sharded index is partitioned, either \"id\" or \"hash\"",
+			EnvVar: "ACOUSTID_API_INDEX_PARTITIONING",
+			Value:  index.PartitionByID,
+		},
+		cli.DurationFlag{
+			Name:   "index-shard-timeout",
+			Usage:  "how long to wait for results from one index shard, 0 means a part of the remaining request time",
+			EnvVar: "ACOUSTID_API_INDEX_SHARD_TIMEOUT",
+		},
 		cli.StringFlag{
 			Name:   "fingerprint-db-url",
 			Usage:  "fingerprint database URL",
 			EnvVar: "ACOUSTID_API_FINGERPRINT_DB_URL",
 			Value:  "postgresql://127.0.0.1:5432/acoustid",
 		},
+		cli.StringFlag{
+			Name:   "musicbrainz-db-url",
+			Usage:  "musicbrainz database URL",
+			EnvVar: "ACOUSTID_API_MUSICBRAINZ_DB_URL",
+			Value:  "postgresql://127.0.0.1:5432/musicbrainz",
+		},
+		cli.StringFlag{
+			Name:   "app-db-url",
+			Usage:  "app database URL",
+			EnvVar: "ACOUSTID_API_APP_DB_URL",
+			Value:  "postgresql://127.0.0.1:5432/acoustid_app",
+		},
+		cli.StringFlag{
+			Name:   "ingest-db-url",
+			Usage:  "ingest database URL",
+			EnvVar: "ACOUSTID_API_INGEST_DB_URL",
+			Value:  "postgresql://127.0.0.1:5432/acoustid_ingest",
+		},
+		cli.BoolFlag{
+			Name:   "local-scoring",
+			Usage:  "compare fingerprints in the API server instead of the fingerprint database",
+			EnvVar: "ACOUSTID_API_LOCAL_SCORING",
+		},
+		cli.IntFlag{
+			Name:   "fingerprint-cache-size",
+			Usage:  "memory used for caching fingerprints with local scoring, in megabytes, 0 disables the cache",
+			EnvVar: "ACOUSTID_API_FINGERPRINT_CACHE_SIZE",
+			Value:  256,
+		},
+		cli.DurationFlag{
+			Name:   "fingerprint-cache-metadata-ttl",
+			Usage:  "how long cached tracks and submission counts of fingerprints are used with local scoring, 0 disables caching them",
+			EnvVar: "ACOUSTID_API_FINGERPRINT_CACHE_METADATA_TTL",
+			Value:  legacy.DefaultCandidateTTL,
+		},
+		cli.IntFlag{
+			Name:   "search-cache-size",
+			Usage:  "maximum number of cached search results, 0 disables the cache",
+			EnvVar: "ACOUSTID_API_SEARCH_CACHE_SIZE",
+			Value:  10000,
+		},
+		cli.DurationFlag{
+			Name:   "search-cache-ttl",
+			Usage:  "how long to cache search results",
+			EnvVar: "ACOUSTID_API_SEARCH_CACHE_TTL",
+			Value:  time.Minute,
+		},
+		cli.DurationFlag{
+			Name:   "search-max-duration-diff",
+			Usage:  "default maximum difference between the searched and matched audio duration",
+			EnvVar: "ACOUSTID_API_SEARCH_MAX_DURATION_DIFF",
+			Value:  services.DefaultMaxDurationDiff,
+		},
+		cli.DurationFlag{
+			Name:   "search-max-duration-diff-limit",
+			Usage:  "largest maxdurationdiff clients can request",
+			EnvVar: "ACOUSTID_API_SEARCH_MAX_DURATION_DIFF_LIMIT",
+			Value:  30 * time.Second,
+		},
+		cli.IntFlag{
+			Name:   "search-max-results",
+			Usage:  "default maximum number of search results, 0 means no limit",
+			EnvVar: "ACOUSTID_API_SEARCH_MAX_RESULTS",
+		},
+		cli.IntFlag{
+			Name:   "search-max-results-limit",
+			Usage:  "largest maxresults clients can request, 0 means no limit",
+			EnvVar: "ACOUSTID_API_SEARCH_MAX_RESULTS_LIMIT",
+		},
+		cli.Float64Flag{
+			Name:   "search-min-score",
+			Usage:  "minimum score of search results",
+			EnvVar: "ACOUSTID_API_SEARCH_MIN_SCORE",
+		},
+		cli.IntFlag{
+			Name:   "search-max-candidates",
+			Usage:  "default maximum number of index candidates to score",
+			EnvVar: "ACOUSTID_API_SEARCH_MAX_CANDIDATES",
+			Value:  11,
+		},
+		cli.IntFlag{
+			Name:   "search-max-candidates-limit",
+			Usage:  "largest maxcandidates clients can request",
+			EnvVar: "ACOUSTID_API_SEARCH_MAX_CANDIDATES_LIMIT",
+			Value:  50,
+		},
+		cli.IntFlag{
+			Name:   "search-min-hits",
+			Usage:  "minimum number of matching hashes of the best index candidate",
+			EnvVar: "ACOUSTID_API_SEARCH_MIN_HITS",
+			Value:  2,
+		},
+		cli.IntFlag{
+			Name:   "search-min-hits-percent",
+			Usage:  "minimum number of matching hashes of other index candidates, relative to the best one",
+			EnvVar: "ACOUSTID_API_SEARCH_MIN_HITS_PERCENT",
+			Value:  50,
+		},
+		cli.DurationFlag{
+			Name:   "application-cache-ttl",
+			Usage:  "how long to cache API key lookups",
+			EnvVar: "ACOUSTID_API_APPLICATION_CACHE_TTL",
+			Value:  time.Minute,
+		},
+		cli.IntFlag{
+			Name:   "application-cache-size",
+			Usage:  "maximum number of cached API keys",
+			EnvVar: "ACOUSTID_API_APPLICATION_CACHE_SIZE",
+			Value:  10000,
+		},
+		cli.StringFlag{
+			Name:   "rate-limit-ip",
+			Usage:  "maximum number of requests per second from one IP address, in the rate[:burst] format",
+			EnvVar: "ACOUSTID_API_RATE_LIMIT_IP",
+			Value:  "3",
+		},
+		cli.StringFlag{
+			Name:   "rate-limit-app",
+			Usage:  "maximum number of requests per second from one application, in the rate[:burst] format",
+			EnvVar: "ACOUSTID_API_RATE_LIMIT_APP",
+			Value:  "0",
+		},
+		cli.StringSliceFlag{
+			Name:   "rate-limit-app-override",
+			Usage:  "custom rate limit for one application, in the apikey=rate[:burst] format",
+			EnvVar: "ACOUSTID_API_RATE_LIMIT_APP_OVERRIDE",
+		},
+		cli.StringFlag{
+			Name:   "real-ip-header",
+			Usage:  "HTTP header containing the client IP address, when running behind a proxy",
+			EnvVar: "ACOUSTID_API_REAL_IP_HEADER",
+		},
+		cli.StringSliceFlag{
+			Name:   "trusted-proxy",
+			Usage:  "IP address or network of a proxy to skip when reading the client IP address from real-ip-header",
+			EnvVar: "ACOUSTID_API_TRUSTED_PROXY",
+		},
+		cli.DurationFlag{
+			Name:   "stats-flush-interval",
+			Usage:  "how often to write lookup statistics to the app database",
+			EnvVar: "ACOUSTID_API_STATS_FLUSH_INTERVAL",
+			Value:  time.Minute,
+		},
+		cli.StringFlag{
+			Name:   "redis-address",
+			Usage:  "address of the Redis server used for sharing rate limits, if empty they are kept in memory",
+			EnvVar: "ACOUSTID_API_REDIS_ADDRESS",
+		},
 	},
 }
 
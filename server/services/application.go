@@ -0,0 +1,149 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type Application struct {
+	ID        int
+	Name      string
+	Version   string
+	APIKey    string
+	AccountID int
+	Active    bool
+}
+
+type ApplicationService interface {
+	// GetApplicationByAPIKey returns the application with the given API key, or nil if the key is not known.
+	GetApplicationByAPIKey(ctx context.Context, apiKey string) (*Application, error)
+}
+
+var errApplicationLookupPanicked = errors.New("application lookup panicked")
+
+type cachedApplication struct {
+	apiKey  string
+	app     *Application
+	expires time.Time
+}
+
+type applicationCall struct {
+	done chan struct{}
+	app  *Application
+	err  error
+}
+
+// CachedApplicationService keeps results of API key lookups in memory for a limited time,
+// so that validating the API key does not require a database query on every request.
+// Unknown API keys are cached as well. When the cache is full, the least recently used entry is evicted.
+// Concurrent lookups of the same API key are coalesced into one.
+type CachedApplicationService struct {
+	Service ApplicationService
+	TTL     time.Duration
+	MaxSize int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	calls   map[string]*applicationCall
+}
+
+func NewCachedApplicationService(service ApplicationService, ttl time.Duration, maxSize int) *CachedApplicationService {
+	return &CachedApplicationService{
+		Service: service,
+		TTL:     ttl,
+		MaxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		calls:   make(map[string]*applicationCall),
+	}
+}
+
+func (s *CachedApplicationService) GetApplicationByAPIKey(ctx context.Context, apiKey string) (*Application, error) {
+	for {
+		s.mu.Lock()
+		if app, ok := s.get(apiKey); ok {
+			s.mu.Unlock()
+			return app, nil
+		}
+		if call, exists := s.calls[apiKey]; exists {
+			s.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if call.err != nil && isContextError(call.err) && ctx.Err() == nil {
+				// The lookup was cancelled by its original caller, this one is still active, so try again.
+				continue
+			}
+			return call.app, call.err
+		}
+		call := &applicationCall{done: make(chan struct{})}
+		s.calls[apiKey] = call
+		s.mu.Unlock()
+
+		s.lookup(ctx, apiKey, call)
+		return call.app, call.err
+	}
+}
+
+// lookup runs the lookup for all callers waiting on call. If it panics, the waiting callers get an error
+// and the panic continues in the current goroutine.
+func (s *CachedApplicationService) lookup(ctx context.Context, apiKey string, call *applicationCall) {
+	panicked := true
+	defer func() {
+		if panicked {
+			call.app, call.err = nil, errApplicationLookupPanicked
+		}
+		s.finish(apiKey, call)
+	}()
+	call.app, call.err = s.Service.GetApplicationByAPIKey(ctx, apiKey)
+	panicked = false
+}
+
+func (s *CachedApplicationService) finish(apiKey string, call *applicationCall) {
+	s.mu.Lock()
+	delete(s.calls, apiKey)
+	if call.err == nil {
+		s.add(apiKey, call.app)
+	}
+	s.mu.Unlock()
+	close(call.done)
+}
+
+func (s *CachedApplicationService) get(apiKey string) (*Application, bool) {
+	elem, exists := s.entries[apiKey]
+	if !exists {
+		return nil, false
+	}
+	entry := elem.Value.(*cachedApplication)
+	if !time.Now().Before(entry.expires) {
+		s.lru.Remove(elem)
+		delete(s.entries, apiKey)
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	return entry.app, true
+}
+
+func (s *CachedApplicationService) add(apiKey string, app *Application) {
+	if s.MaxSize <= 0 {
+		return
+	}
+	entry := &cachedApplication{apiKey: apiKey, app: app, expires: time.Now().Add(s.TTL)}
+	if elem, exists := s.entries[apiKey]; exists {
+		elem.Value = entry
+		s.lru.MoveToFront(elem)
+		return
+	}
+	for s.lru.Len() >= s.MaxSize {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*cachedApplication).apiKey)
+	}
+	s.entries[apiKey] = s.lru.PushFront(entry)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingApplicationService struct {
+	calls int
+}
+
+func (s *countingApplicationService) GetApplicationByAPIKey(ctx context.Context, apiKey string) (*Application, error) {
+	s.calls++
+	if apiKey == "unknown" {
+		return nil, nil
+	}
+	return &Application{ID: s.calls, APIKey: apiKey, Active: true}, nil
+}
+
+func TestCachedApplicationService(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingApplicationService{}
+	service := NewCachedApplicationService(inner, time.Minute, 2)
+
+	app, err := service.GetApplicationByAPIKey(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, 1, app.ID)
+
+	app, err = service.GetApplicationByAPIKey(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, 1, app.ID)
+	assert.Equal(t, 1, inner.calls)
+
+	app, err = service.GetApplicationByAPIKey(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, app)
+
+	app, err = service.GetApplicationByAPIKey(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, app)
+	assert.Equal(t, 2, inner.calls)
+
+	// the least recently used entry is evicted
+	app, err = service.GetApplicationByAPIKey(ctx, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, 3, app.ID)
+	assert.Equal(t, 2, service.lru.Len())
+
+	app, err = service.GetApplicationByAPIKey(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, app)
+	assert.Equal(t, 3, inner.calls)
+
+	app, err = service.GetApplicationByAPIKey(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, 4, app.ID)
+}
+
+type blockingApplicationService struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (s *blockingApplicationService) GetApplicationByAPIKey(ctx context.Context, apiKey string) (*Application, error) {
+	atomic.AddInt32(&s.calls, 1)
+	<-s.release
+	return &Application{ID: 1, APIKey: apiKey, Active: true}, nil
+}
+
+func TestCachedApplicationService_Coalesce(t *testing.T) {
+	ctx := context.Background()
+	inner := &blockingApplicationService{release: make(chan struct{})}
+	service := NewCachedApplicationService(inner, time.Minute, 10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app, err := service.GetApplicationByAPIKey(ctx, "foo")
+			if assert.NoError(t, err) {
+				assert.Equal(t, 1, app.ID)
+			}
+		}()
+	}
+	require.Eventually(t, func() bool {
+		service.mu.Lock()
+		defer service.mu.Unlock()
+		return atomic.LoadInt32(&inner.calls) == 1 && len(service.calls) == 1
+	}, time.Second, time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+	assert.Equal(t, int32(1), inner.calls)
+}
+
+func TestCachedApplicationService_Expired(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingApplicationService{}
+	service := NewCachedApplicationService(inner, 0, 10)
+
+	_, err := service.GetApplicationByAPIKey(ctx, "foo")
+	require.NoError(t, err)
+	_, err = service.GetApplicationByAPIKey(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.calls)
+}
@@ -0,0 +1,24 @@
+package legacy
+
+import (
+	"context"
+
+	"github.com/acoustid/go-acoustid/database/app_db"
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+type AccountService struct {
+	AppDB *app_db.AppDB
+}
+
+func NewAccountService(appDB *app_db.AppDB) *AccountService {
+	return &AccountService{AppDB: appDB}
+}
+
+func (s *AccountService) GetAccountByAPIKey(ctx context.Context, apiKey string) (*services.Account, error) {
+	account, err := s.AppDB.GetAccountByAPIKey(ctx, apiKey)
+	if err != nil || account == nil {
+		return nil, err
+	}
+	return &services.Account{ID: account.ID, Name: account.Name}, nil
+}
@@ -0,0 +1,31 @@
+package legacy
+
+import (
+	"context"
+
+	"github.com/acoustid/go-acoustid/database/app_db"
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+type ApplicationService struct {
+	AppDB *app_db.AppDB
+}
+
+func NewApplicationService(appDB *app_db.AppDB) *ApplicationService {
+	return &ApplicationService{AppDB: appDB}
+}
+
+func (s *ApplicationService) GetApplicationByAPIKey(ctx context.Context, apiKey string) (*services.Application, error) {
+	app, err := s.AppDB.GetApplicationByAPIKey(ctx, apiKey)
+	if err != nil || app == nil {
+		return nil, err
+	}
+	return &services.Application{
+		ID:        app.ID,
+		Name:      app.Name,
+		Version:   app.Version,
+		APIKey:    app.APIKey,
+		AccountID: app.AccountID,
+		Active:    app.Active,
+	}, nil
+}
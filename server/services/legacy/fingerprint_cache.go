@@ -0,0 +1,170 @@
+package legacy
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	fingerprintCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "acoustid_api_fingerprint_cache_hits_total",
+		Help: "Number of candidate fingerprints found in the cache",
+	})
+	fingerprintCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "acoustid_api_fingerprint_cache_misses_total",
+		Help: "Number of candidate fingerprints not found in the cache",
+	})
+	fingerprintCacheSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "acoustid_api_fingerprint_cache_size_bytes",
+		Help: "Estimated memory used by cached fingerprints",
+	})
+)
+
+// fingerprintCacheEntryOverhead is the estimated memory used by one cache entry, including the candidate metadata,
+// but not counting the hashes.
+const fingerprintCacheEntryOverhead = 192
+
+// DefaultCandidateTTL is the default time for which candidate metadata is cached.
+const DefaultCandidateTTL = time.Minute
+
+type fingerprintCacheEntry struct {
+	id               int
+	hashes           []uint32
+	candidate        *fingerprint_db.SearchCandidate
+	candidateExpires time.Time
+}
+
+func (e *fingerprintCacheEntry) size() int {
+	return fingerprintCacheEntryOverhead + 4*len(e.hashes)
+}
+
+// FingerprintCache keeps hashes and metadata of recently used fingerprints in memory, up to MaxSize bytes.
+// Fingerprints never change once they are stored, so the cached hashes never need to be invalidated.
+// The metadata, the track and the submission count, changes when tracks are merged or new submissions
+// are imported, so it's only used for CandidateTTL after it was loaded.
+type FingerprintCache struct {
+	MaxSize int
+	// CandidateTTL is how long candidate metadata is cached, 0 disables caching of the metadata.
+	CandidateTTL time.Duration
+
+	mu      sync.Mutex
+	size    int
+	entries map[int]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+func NewFingerprintCache(maxSize int) *FingerprintCache {
+	return &FingerprintCache{
+		MaxSize:      maxSize,
+		CandidateTTL: DefaultCandidateTTL,
+		entries:      make(map[int]*list.Element),
+		lru:          list.New(),
+		now:          time.Now,
+	}
+}
+
+// Get returns hashes of the fingerprint, if they are in the cache.
+func (c *FingerprintCache) Get(id int) ([]uint32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, exists := c.entries[id]
+	if !exists || elem.Value.(*fingerprintCacheEntry).hashes == nil {
+		fingerprintCacheMisses.Inc()
+		return nil, false
+	}
+	fingerprintCacheHits.Inc()
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*fingerprintCacheEntry).hashes, true
+}
+
+// Add stores hashes of the fingerprint, evicting the least recently used fingerprints if the cache is full.
+func (c *FingerprintCache) Add(id int, hashes []uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[id]
+	if !exists {
+		c.insert(&fingerprintCacheEntry{id: id, hashes: hashes})
+		return
+	}
+	entry := elem.Value.(*fingerprintCacheEntry)
+	if entry.hashes != nil {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	// The entry only had metadata, it's inserted again with the new size.
+	c.remove(elem)
+	entry.hashes = hashes
+	c.insert(entry)
+}
+
+// GetCandidate returns metadata of the fingerprint, if it's in the cache and not older than CandidateTTL.
+func (c *FingerprintCache) GetCandidate(id int) (fingerprint_db.SearchCandidate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, exists := c.entries[id]
+	if !exists {
+		return fingerprint_db.SearchCandidate{}, false
+	}
+	entry := elem.Value.(*fingerprintCacheEntry)
+	if entry.candidate == nil || !c.now().Before(entry.candidateExpires) {
+		return fingerprint_db.SearchCandidate{}, false
+	}
+	c.lru.MoveToFront(elem)
+	return *entry.candidate, true
+}
+
+// AddCandidate stores metadata of the fingerprint for CandidateTTL.
+func (c *FingerprintCache) AddCandidate(candidate fingerprint_db.SearchCandidate) {
+	if c.CandidateTTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.CandidateTTL)
+	if elem, exists := c.entries[candidate.FingerprintID]; exists {
+		entry := elem.Value.(*fingerprintCacheEntry)
+		entry.candidate = &candidate
+		entry.candidateExpires = expires
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.insert(&fingerprintCacheEntry{id: candidate.FingerprintID, candidate: &candidate, candidateExpires: expires})
+}
+
+// Len returns the number of cached fingerprints.
+func (c *FingerprintCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *FingerprintCache) insert(entry *fingerprintCacheEntry) {
+	if entry.size() > c.MaxSize {
+		return
+	}
+	for c.size+entry.size() > c.MaxSize {
+		c.remove(c.lru.Back())
+	}
+	c.entries[entry.id] = c.lru.PushFront(entry)
+	c.updateSize(entry.size())
+}
+
+func (c *FingerprintCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*fingerprintCacheEntry)
+	delete(c.entries, entry.id)
+	c.updateSize(-entry.size())
+}
+
+func (c *FingerprintCache) updateSize(delta int) {
+	c.size += delta
+	fingerprintCacheSize.Add(float64(delta))
+}
@@ -0,0 +1,96 @@
+package legacy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprintCache(t *testing.T) {
+	entrySize := fingerprintCacheEntryOverhead + 4*10
+	cache := NewFingerprintCache(3 * entrySize)
+
+	hashes := func(x uint32) []uint32 {
+		h := make([]uint32, 10)
+		for i := range h {
+			h[i] = x
+		}
+		return h
+	}
+
+	cache.Add(1, hashes(1))
+	cache.Add(2, hashes(2))
+	cache.Add(3, hashes(3))
+	assert.Equal(t, 3, cache.Len())
+
+	// make 1 the most recently used, so that 2 is evicted
+	h, ok := cache.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, hashes(1), h)
+
+	cache.Add(4, hashes(4))
+	assert.Equal(t, 3, cache.Len())
+	_, ok = cache.Get(2)
+	assert.False(t, ok)
+	for _, id := range []int{1, 3, 4} {
+		_, ok = cache.Get(id)
+		assert.True(t, ok, "fingerprint %d", id)
+	}
+
+	// too large to be cached at all
+	cache.Add(5, make([]uint32, 1000))
+	_, ok = cache.Get(5)
+	assert.False(t, ok)
+	assert.Equal(t, 3, cache.Len())
+}
+
+func TestFingerprintCacheCandidates(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewFingerprintCache(2*fingerprintCacheEntryOverhead + 4*10)
+	cache.now = func() time.Time { return now }
+
+	candidate := fingerprint_db.SearchCandidate{FingerprintID: 1, Length: 100, TrackID: 10, SubmissionCount: 1}
+	cache.AddCandidate(candidate)
+	c, ok := cache.GetCandidate(1)
+	assert.True(t, ok)
+	assert.Equal(t, candidate, c)
+
+	// metadata alone doesn't make the hashes available
+	_, ok = cache.Get(1)
+	assert.False(t, ok)
+
+	cache.Add(1, make([]uint32, 10))
+	_, ok = cache.Get(1)
+	assert.True(t, ok)
+	_, ok = cache.GetCandidate(1)
+	assert.True(t, ok)
+
+	// the metadata expires, the hashes don't
+	now = now.Add(cache.CandidateTTL)
+	_, ok = cache.GetCandidate(1)
+	assert.False(t, ok)
+	_, ok = cache.Get(1)
+	assert.True(t, ok)
+
+	candidate.SubmissionCount = 2
+	cache.AddCandidate(candidate)
+	c, ok = cache.GetCandidate(1)
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.SubmissionCount)
+	assert.Equal(t, 1, cache.Len())
+
+	// metadata-only entries are evicted like the others
+	cache.AddCandidate(fingerprint_db.SearchCandidate{FingerprintID: 2})
+	cache.AddCandidate(fingerprint_db.SearchCandidate{FingerprintID: 3})
+	assert.Equal(t, 2, cache.Len())
+	_, ok = cache.Get(1)
+	assert.False(t, ok)
+
+	// with no TTL, metadata is not cached
+	cache.CandidateTTL = 0
+	cache.AddCandidate(fingerprint_db.SearchCandidate{FingerprintID: 4})
+	_, ok = cache.GetCandidate(4)
+	assert.False(t, ok)
+}
@@ -9,35 +9,35 @@ import (
 
 	"github.com/acoustid/go-acoustid/chromaprint"
 	"github.com/acoustid/go-acoustid/database/fingerprint_db"
-	"github.com/acoustid/go-acoustid/proto/index"
+	"github.com/acoustid/go-acoustid/index"
+	pb "github.com/acoustid/go-acoustid/proto/index"
 	"github.com/acoustid/go-acoustid/server/services"
 )
 
 const indexQueryStart = 80
 const indexQueryLength = 120
-const indexQueryHashBits = 28
-const indexQueryHashMask = ((1 << indexQueryHashBits) - 1) << (32 - indexQueryHashBits)
 
 const silenceHash = 627964279
 
 type IndexSearcher interface {
-	Search(ctx context.Context, in *index.SearchRequest) (*index.SearchResponse, error)
+	Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error)
+}
+
+// MatchScorer compares the searched fingerprint with candidate fingerprints.
+type MatchScorer interface {
+	ScoreSearchMatches(ctx context.Context, hashes []uint32, candidateIDs []int, duration time.Duration, maxDurationDiff time.Duration) ([]fingerprint_db.ScoredSearchMatch, error)
 }
 
 type FingerprintSearcher struct {
-	Index          IndexSearcher
-	FingerprintDB  *fingerprint_db.FingerprintDB
-	MaxCandidates  int
-	MinHits        int
-	MinHitsPercent int
+	Index IndexSearcher
+	// FingerprintDB is used for following merges of the matched tracks.
+	FingerprintDB TrackLoader
+	// Scorer defaults to scoring in the database, using the acoustid_compare2 function.
+	Scorer MatchScorer
 }
 
 func NewFingerprintSearcher(index IndexSearcher, fingerprintDB *fingerprint_db.FingerprintDB) *FingerprintSearcher {
-	searcher := &FingerprintSearcher{Index: index, FingerprintDB: fingerprintDB}
-	searcher.MaxCandidates = 10
-	searcher.MinHits = 2
-	searcher.MinHitsPercent = 50
-	return searcher
+	return &FingerprintSearcher{Index: index, FingerprintDB: fingerprintDB, Scorer: fingerprintDB}
 }
 
 func (searcher *FingerprintSearcher) ExtractIndexQuery(hashes []uint32) []uint32 {
@@ -64,7 +64,7 @@ func (searcher *FingerprintSearcher) ExtractIndexQuery(hashes []uint32) []uint32
 	end := size
 	for i, hash := range hashes[start:] {
 		if hash != silenceHash {
-			hash &= indexQueryHashMask
+			hash &= index.QueryHashMask
 			if _, exists := queryMap[hash]; !exists {
 				query = append(query, hash)
 				queryMap[hash] = true
@@ -79,8 +79,8 @@ func (searcher *FingerprintSearcher) ExtractIndexQuery(hashes []uint32) []uint32
 	return query
 }
 
-func (searcher *FingerprintSearcher) GetCandidates(ctx context.Context, hashes []uint32) ([]int, error) {
-	response, err := searcher.Index.Search(ctx, &index.SearchRequest{Hashes: hashes})
+func (searcher *FingerprintSearcher) GetCandidates(ctx context.Context, hashes []uint32, opts services.SearchOptions) ([]int, error) {
+	response, err := searcher.Index.Search(ctx, &pb.SearchRequest{Hashes: hashes})
 	if err != nil {
 		return nil, fmt.Errorf("index search failed: %w", err)
 	}
@@ -92,14 +92,14 @@ func (searcher *FingerprintSearcher) GetCandidates(ctx context.Context, hashes [
 
 	sort.Slice(results, func(i, j int) bool { return results[i].Hits >= results[j].Hits })
 
-	minHits := int(results[0].Hits) * searcher.MinHitsPercent / 100
-	if minHits < searcher.MinHits {
+	minHits := int(results[0].Hits) * opts.MinHitsPercent / 100
+	if minHits < opts.MinHits {
 		return nil, nil
 	}
 
 	candidates := make([]int, 0)
 	for i, result := range results {
-		if i > searcher.MaxCandidates || int(result.Hits) < minHits {
+		if i >= opts.MaxCandidates || int(result.Hits) < minHits {
 			break
 		}
 		candidates = append(candidates, int(result.Id))
@@ -108,25 +108,48 @@ func (searcher *FingerprintSearcher) GetCandidates(ctx context.Context, hashes [
 	return candidates, nil
 }
 
-func (s *FingerprintSearcher) Search(ctx context.Context, fingerprint chromaprint.Fingerprint, duration time.Duration) ([]services.FingerprintSearchResult, error) {
+func (s *FingerprintSearcher) Search(ctx context.Context, fingerprint chromaprint.Fingerprint, duration time.Duration, opts services.SearchOptions) ([]services.FingerprintSearchResult, error) {
 	log.Printf("Searching for fingerprint with %v hashess", len(fingerprint.Hashes))
-	candidates, err := s.GetCandidates(ctx, s.ExtractIndexQuery(fingerprint.Hashes))
+	candidates, err := s.GetCandidates(ctx, s.ExtractIndexQuery(fingerprint.Hashes), opts)
 	if err != nil {
 		return nil, err
 	}
 
-	matches, err := s.FingerprintDB.ScoreSearchMatches(ctx, fingerprint.Hashes, candidates, duration)
+	matches, err := s.Scorer.ScoreSearchMatches(ctx, fingerprint.Hashes, candidates, duration, opts.MaxDurationDiff)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := make([]fingerprint_db.Track, len(matches))
+	for i, match := range matches {
+		tracks[i] = fingerprint_db.Track{ID: match.TrackID, GID: match.TrackGID, NewID: match.TrackNewID}
+	}
+	canonicalTracks, err := resolveMergedTracks(ctx, s.FingerprintDB, tracks)
 	if err != nil {
 		return nil, err
 	}
 
 	results := make([]services.FingerprintSearchResult, len(matches))
 	for i, match := range matches {
+		track := canonicalTracks[match.TrackID]
 		results[i] = services.FingerprintSearchResult{
-			TrackID:  match.TrackID,
-			TrackGID: match.TrackGID,
-			Score:    match.Score,
+			TrackID:         track.ID,
+			TrackGID:        track.GID,
+			Score:           match.Score,
+			SubmissionCount: match.SubmissionCount,
+		}
+	}
+	results = services.AggregateSearchResults(results)
+
+	filtered := results[:0]
+	for _, result := range results {
+		if result.Score < opts.MinScore {
+			continue
+		}
+		if opts.MaxResults > 0 && len(filtered) >= opts.MaxResults {
+			break
 		}
+		filtered = append(filtered, result)
 	}
-	return results, nil
+	return filtered, nil
 }
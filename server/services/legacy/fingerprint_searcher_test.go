@@ -0,0 +1,84 @@
+package legacy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/acoustid/go-acoustid/chromaprint"
+	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+	"github.com/acoustid/go-acoustid/index"
+	pb "github.com/acoustid/go-acoustid/proto/index"
+	"github.com/acoustid/go-acoustid/server/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprintSearcher_GetCandidates(t *testing.T) {
+	ctx := context.Background()
+	idx := index.NewMemoryIndex()
+	_, err := idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{
+		{Id: 1, Hashes: []uint32{0x10, 0x20, 0x30, 0x40}},
+		{Id: 2, Hashes: []uint32{0x10, 0x20, 0x30}},
+		{Id: 3, Hashes: []uint32{0x10}},
+	}})
+	require.NoError(t, err)
+
+	searcher := NewFingerprintSearcher(idx, nil)
+	query := searcher.ExtractIndexQuery([]uint32{0x11, 0x21, 0x31, 0x41})
+	assert.Equal(t, []uint32{0x10, 0x20, 0x30, 0x40}, query)
+
+	opts := services.NewSearchOptions()
+	candidates, err := searcher.GetCandidates(ctx, query, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, candidates)
+
+	opts.MaxCandidates = 1
+	candidates, err = searcher.GetCandidates(ctx, query, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, candidates)
+
+	opts.MinHits = 5
+	candidates, err = searcher.GetCandidates(ctx, query, opts)
+	require.NoError(t, err)
+	assert.Empty(t, candidates)
+}
+
+type mockMatchScorer struct {
+	matches []fingerprint_db.ScoredSearchMatch
+}
+
+func (s *mockMatchScorer) ScoreSearchMatches(ctx context.Context, hashes []uint32, candidateIDs []int, duration time.Duration, maxDurationDiff time.Duration) ([]fingerprint_db.ScoredSearchMatch, error) {
+	return s.matches, nil
+}
+
+func TestFingerprintSearcher_MergedTracks(t *testing.T) {
+	ctx := context.Background()
+	idx := index.NewMemoryIndex()
+	_, err := idx.Insert(ctx, &pb.InsertRequest{Fingerprints: []*pb.Fingerprint{
+		{Id: 1, Hashes: []uint32{0x10, 0x20, 0x30, 0x40}},
+		{Id: 2, Hashes: []uint32{0x10, 0x20, 0x30, 0x40}},
+		{Id: 3, Hashes: []uint32{0x10, 0x20, 0x30}},
+	}})
+	require.NoError(t, err)
+
+	searcher := NewFingerprintSearcher(idx, nil)
+	searcher.FingerprintDB = newMockTrackLoader(
+		fingerprint_db.Track{ID: 10, GID: "a", NewID: 20},
+		fingerprint_db.Track{ID: 20, GID: "b"},
+	)
+	searcher.Scorer = &mockMatchScorer{matches: []fingerprint_db.ScoredSearchMatch{
+		{FingerprintID: 1, TrackID: 10, TrackGID: "a", TrackNewID: 20, Score: 0.9, SubmissionCount: 3},
+		{FingerprintID: 3, TrackID: 30, TrackGID: "c", Score: 0.8, SubmissionCount: 1},
+		{FingerprintID: 2, TrackID: 20, TrackGID: "b", Score: 0.7, SubmissionCount: 2},
+	}}
+
+	fingerprint := chromaprint.Fingerprint{Version: 1, Hashes: []uint32{0x11, 0x21, 0x31, 0x41}}
+	results, err := searcher.Search(ctx, fingerprint, 100*time.Second, services.NewSearchOptions())
+	require.NoError(t, err)
+	// the match of the merged track is reported under the track it was merged into
+	assert.Equal(t, []services.FingerprintSearchResult{
+		{TrackID: 20, TrackGID: "b", Score: 0.9, SubmissionCount: 5},
+		{TrackID: 30, TrackGID: "c", Score: 0.8, SubmissionCount: 1},
+	}, results)
+}
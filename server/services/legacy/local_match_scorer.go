@@ -0,0 +1,141 @@
+package legacy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/acoustid/go-acoustid/chromaprint"
+	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+)
+
+// SearchCandidateStore loads candidate fingerprints for scoring, it's implemented by fingerprint_db.FingerprintDB.
+type SearchCandidateStore interface {
+	GetSearchCandidates(ctx context.Context, candidateIDs []int) ([]fingerprint_db.SearchCandidate, error)
+	GetFingerprintHashes(ctx context.Context, ids []int) (map[int][]uint32, error)
+}
+
+// LocalMatchScorer loads the candidate fingerprints from the database and compares them in the API process,
+// so that the database doesn't need the acoustid_compare2 extension and doesn't spend CPU time on scoring.
+//
+// With a cache, repeated candidates don't touch the database. Their tracks and submission counts are
+// reused for the cache's CandidateTTL, so merges and new submissions show up in results after at most that long.
+type LocalMatchScorer struct {
+	FingerprintDB SearchCandidateStore
+	// Cache is optional, if set, repeated candidates are not loaded from the database again.
+	Cache *FingerprintCache
+}
+
+func NewLocalMatchScorer(fingerprintDB SearchCandidateStore, cache *FingerprintCache) *LocalMatchScorer {
+	return &LocalMatchScorer{FingerprintDB: fingerprintDB, Cache: cache}
+}
+
+func (s *LocalMatchScorer) ScoreSearchMatches(ctx context.Context, hashes []uint32, candidateIDs []int, duration time.Duration, maxDurationDiff time.Duration) ([]fingerprint_db.ScoredSearchMatch, error) {
+	matches := []fingerprint_db.ScoredSearchMatch{}
+	if len(candidateIDs) == 0 {
+		return matches, nil
+	}
+
+	candidates, err := s.getSearchCandidates(ctx, candidateIDs, duration, maxDurationDiff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load candidates: %w", err)
+	}
+	if len(candidates) == 0 {
+		return matches, nil
+	}
+
+	candidateHashes, err := s.getFingerprintHashes(ctx, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load candidate fingerprints: %w", err)
+	}
+
+	for _, candidate := range candidates {
+		fingerprint, exists := candidateHashes[candidate.FingerprintID]
+		if !exists {
+			continue
+		}
+		matches = append(matches, fingerprint_db.ScoredSearchMatch{
+			FingerprintID:   candidate.FingerprintID,
+			TrackID:         candidate.TrackID,
+			TrackGID:        candidate.TrackGID,
+			TrackNewID:      candidate.TrackNewID,
+			Score:           scoreToFloat64(chromaprint.CompareFingerprints(fingerprint, hashes, 0)),
+			SubmissionCount: candidate.SubmissionCount,
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches, nil
+}
+
+func (s *LocalMatchScorer) getSearchCandidates(ctx context.Context, candidateIDs []int, duration time.Duration, maxDurationDiff time.Duration) ([]fingerprint_db.SearchCandidate, error) {
+	var candidates []fingerprint_db.SearchCandidate
+	var missingIDs []int
+	for _, id := range candidateIDs {
+		if s.Cache != nil {
+			if candidate, exists := s.Cache.GetCandidate(id); exists {
+				candidates = append(candidates, candidate)
+				continue
+			}
+		}
+		missingIDs = append(missingIDs, id)
+	}
+	if len(missingIDs) > 0 {
+		loaded, err := s.FingerprintDB.GetSearchCandidates(ctx, missingIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range loaded {
+			candidates = append(candidates, candidate)
+			if s.Cache != nil {
+				s.Cache.AddCandidate(candidate)
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].FingerprintID < candidates[j].FingerprintID })
+
+	filtered := candidates[:0]
+	for _, candidate := range candidates {
+		if candidate.MatchesDuration(duration, maxDurationDiff) {
+			filtered = append(filtered, candidate)
+		}
+	}
+	return filtered, nil
+}
+
+func (s *LocalMatchScorer) getFingerprintHashes(ctx context.Context, candidates []fingerprint_db.SearchCandidate) (map[int][]uint32, error) {
+	hashes := make(map[int][]uint32, len(candidates))
+	var missingIDs []int
+	for _, candidate := range candidates {
+		if s.Cache != nil {
+			if fingerprint, exists := s.Cache.Get(candidate.FingerprintID); exists {
+				hashes[candidate.FingerprintID] = fingerprint
+				continue
+			}
+		}
+		missingIDs = append(missingIDs, candidate.FingerprintID)
+	}
+	if len(missingIDs) == 0 {
+		return hashes, nil
+	}
+	loaded, err := s.FingerprintDB.GetFingerprintHashes(ctx, missingIDs)
+	if err != nil {
+		return nil, err
+	}
+	for id, fingerprint := range loaded {
+		hashes[id] = fingerprint
+		if s.Cache != nil {
+			s.Cache.Add(id, fingerprint)
+		}
+	}
+	return hashes, nil
+}
+
+// scoreToFloat64 converts the score to the shortest decimal representation of the float32 value,
+// the same way lib/pq reads a real column returned by acoustid_compare2, so that e.g. 0.9 is not
+// returned as 0.8999999761581421 and local scores are identical to the ones computed in the database.
+func scoreToFloat64(score float32) float64 {
+	value, _ := strconv.ParseFloat(strconv.FormatFloat(float64(score), 'g', -1, 32), 64)
+	return value
+}
@@ -0,0 +1,121 @@
+package legacy
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/acoustid/go-acoustid/chromaprint"
+	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSearchCandidateStore struct {
+	candidates       []fingerprint_db.SearchCandidate
+	hashes           map[int][]uint32
+	loadedCandidates []int
+	loadedHashes     []int
+}
+
+func (s *mockSearchCandidateStore) GetSearchCandidates(ctx context.Context, candidateIDs []int) ([]fingerprint_db.SearchCandidate, error) {
+	s.loadedCandidates = append(s.loadedCandidates, candidateIDs...)
+	requested := make(map[int]bool)
+	for _, id := range candidateIDs {
+		requested[id] = true
+	}
+	var candidates []fingerprint_db.SearchCandidate
+	for _, candidate := range s.candidates {
+		if requested[candidate.FingerprintID] {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates, nil
+}
+
+func (s *mockSearchCandidateStore) GetFingerprintHashes(ctx context.Context, ids []int) (map[int][]uint32, error) {
+	s.loadedHashes = append(s.loadedHashes, ids...)
+	hashes := make(map[int][]uint32)
+	for _, id := range ids {
+		if h, exists := s.hashes[id]; exists {
+			hashes[id] = h
+		}
+	}
+	return hashes, nil
+}
+
+func TestLocalMatchScorer(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+	query := make([]uint32, 200)
+	for i := range query {
+		query[i] = rng.Uint32()
+	}
+	similar := append([]uint32(nil), query...)
+	for i := 0; i < len(similar); i += 2 {
+		similar[i] ^= 1 << uint(rng.Intn(32))
+	}
+
+	store := &mockSearchCandidateStore{
+		candidates: []fingerprint_db.SearchCandidate{
+			{FingerprintID: 1, Length: 100, TrackID: 10, TrackGID: "a", SubmissionCount: 1},
+			{FingerprintID: 2, Length: 101, TrackID: 20, TrackGID: "b", TrackNewID: 21, SubmissionCount: 2},
+			{FingerprintID: 3, Length: 200, TrackID: 30, TrackGID: "c", SubmissionCount: 3},
+			{FingerprintID: 4, Length: 100, TrackID: 40, TrackGID: "d", SubmissionCount: 4},
+		},
+		// fingerprint 4 was deleted after it was returned as a candidate
+		hashes: map[int][]uint32{1: similar, 2: query, 3: query},
+	}
+	now := time.Unix(1000, 0)
+	cache := NewFingerprintCache(1024 * 1024)
+	cache.now = func() time.Time { return now }
+	scorer := NewLocalMatchScorer(store, cache)
+
+	matches, err := scorer.ScoreSearchMatches(ctx, query, []int{1, 2, 3, 4}, 100*time.Second, 7*time.Second)
+	require.NoError(t, err)
+	expected := []fingerprint_db.ScoredSearchMatch{
+		{FingerprintID: 2, TrackID: 20, TrackGID: "b", TrackNewID: 21, Score: 1.0, SubmissionCount: 2},
+		{FingerprintID: 1, TrackID: 10, TrackGID: "a", Score: scoreToFloat64(chromaprint.CompareFingerprints(similar, query, 0)), SubmissionCount: 1},
+	}
+	assert.Equal(t, expected, matches)
+	assert.True(t, matches[1].Score > 0 && matches[1].Score < 1, "score %v", matches[1].Score)
+	assert.ElementsMatch(t, []int{1, 2, 3, 4}, store.loadedCandidates)
+	assert.ElementsMatch(t, []int{1, 2, 4}, store.loadedHashes)
+
+	// repeated candidates are served from the cache, only the deleted fingerprint is loaded again
+	store.loadedCandidates = nil
+	store.loadedHashes = nil
+	matches, err = scorer.ScoreSearchMatches(ctx, query, []int{1, 2, 3, 4}, 100*time.Second, 7*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, expected, matches)
+	assert.Empty(t, store.loadedCandidates)
+	assert.Equal(t, []int{4}, store.loadedHashes)
+
+	// after the TTL, the metadata is loaded again, so that merged tracks show up
+	store.candidates[1].TrackID = 10
+	store.candidates[1].TrackGID = "a"
+	store.candidates[1].TrackNewID = 0
+	store.loadedCandidates = nil
+	store.loadedHashes = nil
+	now = now.Add(DefaultCandidateTTL)
+	matches, err = scorer.ScoreSearchMatches(ctx, query, []int{1, 2, 3, 4}, 100*time.Second, 7*time.Second)
+	require.NoError(t, err)
+	expected[0].TrackID = 10
+	expected[0].TrackGID = "a"
+	expected[0].TrackNewID = 0
+	assert.Equal(t, expected, matches)
+	assert.ElementsMatch(t, []int{1, 2, 3, 4}, store.loadedCandidates)
+	assert.Equal(t, []int{4}, store.loadedHashes)
+
+	matches, err = scorer.ScoreSearchMatches(ctx, query, nil, 100*time.Second, 7*time.Second)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestScoreToFloat64(t *testing.T) {
+	assert.Equal(t, 0.9, scoreToFloat64(0.9))
+	assert.Equal(t, 1.0, scoreToFloat64(1))
+	assert.Equal(t, 0.0, scoreToFloat64(0))
+	assert.Equal(t, 0.1234567, scoreToFloat64(0.1234567))
+}
@@ -0,0 +1,171 @@
+package legacy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+	"github.com/acoustid/go-acoustid/database/musicbrainz_db"
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+type MetadataService struct {
+	FingerprintDB *fingerprint_db.FingerprintDB
+	MusicBrainzDB *musicbrainz_db.MusicBrainzDB
+}
+
+func NewMetadataService(fingerprintDB *fingerprint_db.FingerprintDB, musicBrainzDB *musicbrainz_db.MusicBrainzDB) *MetadataService {
+	return &MetadataService{FingerprintDB: fingerprintDB, MusicBrainzDB: musicBrainzDB}
+}
+
+func (s *MetadataService) GetTrackRecordings(ctx context.Context, trackIDs []int, opts services.MetadataOptions) (map[int][]services.Recording, error) {
+	trackMBIDs, err := s.FingerprintDB.GetTrackMBIDs(ctx, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load track MBIDs: %w", err)
+	}
+
+	results := make(map[int][]services.Recording, len(trackMBIDs))
+
+	if !opts.Recordings && !opts.Releases {
+		for trackID, mbids := range trackMBIDs {
+			for _, mbid := range mbids {
+				results[trackID] = append(results[trackID], services.Recording{ID: mbid})
+			}
+		}
+		return results, nil
+	}
+
+	var mbids []string
+	for _, trackMBIDs := range trackMBIDs {
+		mbids = append(mbids, trackMBIDs...)
+	}
+	recordings, err := s.MusicBrainzDB.GetRecordingsByGIDs(ctx, mbids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recordings: %w", err)
+	}
+
+	var artistCreditIDs []int
+	if opts.Recordings {
+		for _, recording := range recordings {
+			artistCreditIDs = append(artistCreditIDs, recording.ArtistCreditID)
+		}
+	}
+
+	var recordingTracks []musicbrainz_db.RecordingTrack
+	if opts.Releases {
+		recordingIDs := make([]int, 0, len(recordings))
+		for _, recording := range recordings {
+			recordingIDs = append(recordingIDs, recording.ID)
+		}
+		recordingTracks, err = s.MusicBrainzDB.GetRecordingTracks(ctx, recordingIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load recording tracks: %w", err)
+		}
+		for _, t := range recordingTracks {
+			artistCreditIDs = append(artistCreditIDs, t.TrackArtistCreditID, t.ReleaseArtistCreditID, t.ReleaseGroupArtistCreditID)
+		}
+	}
+
+	artistCredits, err := s.MusicBrainzDB.GetArtistCredits(ctx, artistCreditIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load artist credits: %w", err)
+	}
+
+	releases := groupRecordingReleases(recordingTracks, artistCredits)
+
+	for trackID, mbids := range trackMBIDs {
+		seen := make(map[int]bool)
+		for _, mbid := range mbids {
+			recording, exists := recordings[mbid]
+			if !exists || seen[recording.ID] {
+				continue
+			}
+			seen[recording.ID] = true
+			result := services.Recording{ID: recording.GID}
+			if opts.Recordings {
+				result.Title = recording.Name
+				result.Duration = time.Duration(recording.Length) * time.Millisecond
+				result.Artists = convertArtistCredit(artistCredits[recording.ArtistCreditID])
+			}
+			if opts.Releases {
+				result.Releases = releases[recording.ID]
+			}
+			results[trackID] = append(results[trackID], result)
+		}
+	}
+	return results, nil
+}
+
+func (s *MetadataService) GetTrackForeignIDs(ctx context.Context, trackIDs []int) (map[int][]services.ForeignID, error) {
+	rows, err := s.FingerprintDB.GetTrackForeignIDs(ctx, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load track foreign IDs: %w", err)
+	}
+	results := make(map[int][]services.ForeignID, len(rows))
+	for trackID, foreignIDs := range rows {
+		for _, foreignID := range foreignIDs {
+			results[trackID] = append(results[trackID], services.ForeignID{Vendor: foreignID.Vendor, Name: foreignID.Name})
+		}
+	}
+	return results, nil
+}
+
+// groupRecordingReleases converts a list of recording tracks, ordered by recording, release, medium and track,
+// into a tree of releases for each recording.
+func groupRecordingReleases(tracks []musicbrainz_db.RecordingTrack, artistCredits map[int][]musicbrainz_db.ArtistCreditName) map[int][]services.Release {
+	releases := make(map[int][]services.Release)
+	for _, t := range tracks {
+		recordingReleases := releases[t.RecordingID]
+		n := len(recordingReleases)
+		if n == 0 || recordingReleases[n-1].ID != t.ReleaseGID {
+			recordingReleases = append(recordingReleases, services.Release{
+				ID:          t.ReleaseGID,
+				Title:       t.ReleaseName,
+				Artists:     convertArtistCredit(artistCredits[t.ReleaseArtistCreditID]),
+				MediumCount: t.ReleaseMediumCount,
+				ReleaseGroup: services.ReleaseGroup{
+					ID:      t.ReleaseGroupGID,
+					Title:   t.ReleaseGroupName,
+					Type:    t.ReleaseGroupType,
+					Artists: convertArtistCredit(artistCredits[t.ReleaseGroupArtistCreditID]),
+				},
+			})
+			n++
+		}
+		release := &recordingReleases[n-1]
+		m := len(release.Mediums)
+		if m == 0 || release.Mediums[m-1].Position != t.MediumPosition {
+			release.Mediums = append(release.Mediums, services.Medium{
+				Position:   t.MediumPosition,
+				Format:     t.MediumFormat,
+				TrackCount: t.MediumTrackCount,
+			})
+			m++
+		}
+		medium := &release.Mediums[m-1]
+		medium.Tracks = append(medium.Tracks, services.Track{
+			ID:       t.TrackGID,
+			Title:    t.TrackName,
+			Position: t.TrackPosition,
+			Artists:  convertArtistCredit(artistCredits[t.TrackArtistCreditID]),
+		})
+		releases[t.RecordingID] = recordingReleases
+	}
+	return releases
+}
+
+func convertArtistCredit(names []musicbrainz_db.ArtistCreditName) []services.Artist {
+	if len(names) == 0 {
+		return nil
+	}
+	artists := make([]services.Artist, len(names))
+	for i, name := range names {
+		artists[i] = services.Artist{
+			ID:         name.ArtistGID,
+			Name:       name.Name,
+			JoinPhrase: name.JoinPhrase,
+		}
+	}
+	return artists
+}
@@ -0,0 +1,31 @@
+package legacy
+
+import (
+	"context"
+
+	"github.com/acoustid/go-acoustid/database/app_db"
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+type StatsService struct {
+	AppDB *app_db.AppDB
+}
+
+func NewStatsService(appDB *app_db.AppDB) *StatsService {
+	return &StatsService{AppDB: appDB}
+}
+
+func (s *StatsService) UpdateLookupStats(ctx context.Context, stats []services.LookupStats) error {
+	rows := make([]app_db.LookupStats, len(stats))
+	for i, stat := range stats {
+		hour := stat.Hour.UTC()
+		rows[i] = app_db.LookupStats{
+			Date:          hour,
+			Hour:          hour.Hour(),
+			ApplicationID: stat.ApplicationID,
+			Hits:          stat.Hits,
+			NoHits:        stat.NoHits,
+		}
+	}
+	return s.AppDB.UpdateLookupStats(ctx, rows)
+}
@@ -0,0 +1,126 @@
+package legacy
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/acoustid/go-acoustid/database/app_db"
+	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+	"github.com/acoustid/go-acoustid/database/ingest_db"
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+type SubmissionService struct {
+	AppDB         *app_db.AppDB
+	FingerprintDB *fingerprint_db.FingerprintDB
+	IngestDB      *ingest_db.IngestDB
+}
+
+func NewSubmissionService(appDB *app_db.AppDB, fingerprintDB *fingerprint_db.FingerprintDB, ingestDB *ingest_db.IngestDB) *SubmissionService {
+	return &SubmissionService{AppDB: appDB, FingerprintDB: fingerprintDB, IngestDB: ingestDB}
+}
+
+func (s *SubmissionService) Submit(ctx context.Context, app *services.Application, account *services.Account, clientVersion string, submissions []services.Submission) ([]int, error) {
+	if len(submissions) == 0 {
+		return nil, nil
+	}
+
+	sourceID, err := s.AppDB.FindOrInsertSource(ctx, app.ID, account.ID, clientVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find source: %w", err)
+	}
+
+	formatIDs := make(map[string]int)
+	// Submissions of one item with multiple MBIDs share the meta pointer, the meta is inserted once for all of them.
+	metaIDs := make(map[*services.SubmissionMeta]int)
+	rows := make([]ingest_db.Submission, len(submissions))
+	for i, submission := range submissions {
+		row := ingest_db.Submission{
+			Fingerprint: submission.Fingerprint.Hashes,
+			Length:      int(math.Round(submission.Duration.Seconds())),
+			Bitrate:     submission.Bitrate,
+			SourceID:    sourceID,
+			MBID:        submission.MBID,
+			PUID:        submission.PUID,
+		}
+		if submission.Format != "" {
+			formatID, exists := formatIDs[submission.Format]
+			if !exists {
+				formatID, err = s.AppDB.FindOrInsertFormat(ctx, submission.Format)
+				if err != nil {
+					return nil, fmt.Errorf("failed to find format: %w", err)
+				}
+				formatIDs[submission.Format] = formatID
+			}
+			row.FormatID = formatID
+		}
+		if submission.Meta != nil {
+			metaID, exists := metaIDs[submission.Meta]
+			if !exists {
+				metaID, err = s.FingerprintDB.InsertMeta(ctx, &fingerprint_db.Meta{
+					Track:       submission.Meta.Track,
+					Artist:      submission.Meta.Artist,
+					Album:       submission.Meta.Album,
+					AlbumArtist: submission.Meta.AlbumArtist,
+					TrackNo:     submission.Meta.TrackNo,
+					DiscNo:      submission.Meta.DiscNo,
+					Year:        submission.Meta.Year,
+				})
+				if err != nil {
+					return nil, fmt.Errorf("failed to insert meta: %w", err)
+				}
+				metaIDs[submission.Meta] = metaID
+			}
+			row.MetaID = metaID
+		}
+		if submission.ForeignID != nil {
+			row.ForeignID, err = s.FingerprintDB.FindOrInsertForeignID(ctx, submission.ForeignID.Vendor, submission.ForeignID.Name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find foreign ID: %w", err)
+			}
+		}
+		rows[i] = row
+	}
+
+	ids, err := s.IngestDB.InsertSubmissions(ctx, rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert submissions: %w", err)
+	}
+	return ids, nil
+}
+
+func (s *SubmissionService) GetSubmissionStatuses(ctx context.Context, ids []int) ([]services.SubmissionStatus, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	submissions, err := s.IngestDB.GetSubmissions(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load submissions: %w", err)
+	}
+
+	var fingerprintIDs []int
+	for _, submission := range submissions {
+		if submission.FingerprintID != 0 {
+			fingerprintIDs = append(fingerprintIDs, submission.FingerprintID)
+		}
+	}
+	var tracks map[int]fingerprint_db.FingerprintTrack
+	if len(fingerprintIDs) > 0 {
+		tracks, err = s.FingerprintDB.GetFingerprintTracks(ctx, fingerprintIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tracks: %w", err)
+		}
+	}
+
+	statuses := make([]services.SubmissionStatus, len(submissions))
+	for i, submission := range submissions {
+		statuses[i] = services.SubmissionStatus{
+			ID:       submission.ID,
+			Handled:  submission.Handled,
+			TrackGID: tracks[submission.FingerprintID].TrackGID,
+		}
+	}
+	return statuses, nil
+}
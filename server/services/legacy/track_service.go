@@ -0,0 +1,139 @@
+package legacy
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+	"github.com/acoustid/go-acoustid/server/services"
+)
+
+// maxMergeDepth limits how many track.new_id links are followed, in case the merges form a cycle.
+const maxMergeDepth = 10
+
+// TrackLoader loads tracks by their IDs, it's implemented by fingerprint_db.FingerprintDB.
+type TrackLoader interface {
+	GetTracks(ctx context.Context, ids []int) (map[int]fingerprint_db.Track, error)
+}
+
+type TrackService struct {
+	FingerprintDB *fingerprint_db.FingerprintDB
+}
+
+func NewTrackService(fingerprintDB *fingerprint_db.FingerprintDB) *TrackService {
+	return &TrackService{FingerprintDB: fingerprintDB}
+}
+
+func (s *TrackService) GetTrackByGID(ctx context.Context, gid string) (*services.TrackRef, error) {
+	track, err := s.FingerprintDB.GetTrackByGID(ctx, gid)
+	if err != nil || track == nil {
+		return nil, err
+	}
+	resolved, err := resolveMergedTracks(ctx, s.FingerprintDB, []fingerprint_db.Track{*track})
+	if err != nil {
+		return nil, err
+	}
+	canonical := resolved[track.ID]
+	return &services.TrackRef{ID: canonical.ID, GID: canonical.GID}, nil
+}
+
+// resolveMergedTracks follows track.new_id links and returns the track each of the given tracks
+// was eventually merged into, keyed by the original track ID. Tracks that were not merged map to themselves.
+// If the links form a cycle or are too long, the last track before the problem is used.
+func resolveMergedTracks(ctx context.Context, db TrackLoader, tracks []fingerprint_db.Track) (map[int]fingerprint_db.Track, error) {
+	resolved := make(map[int]fingerprint_db.Track, len(tracks))
+	pending := make(map[int]fingerprint_db.Track)
+	visited := make(map[int]map[int]bool)
+	for _, track := range tracks {
+		if track.NewID == 0 {
+			resolved[track.ID] = track
+			continue
+		}
+		pending[track.ID] = track
+		visited[track.ID] = map[int]bool{track.ID: true}
+	}
+
+	for depth := 0; len(pending) > 0; depth++ {
+		if depth >= maxMergeDepth {
+			for id, track := range pending {
+				log.Printf("Track %d has too many merges, stopping at track %d", id, track.ID)
+				resolved[id] = track
+			}
+			break
+		}
+
+		var newIDs []int
+		seen := make(map[int]bool)
+		for _, track := range pending {
+			if !seen[track.NewID] {
+				seen[track.NewID] = true
+				newIDs = append(newIDs, track.NewID)
+			}
+		}
+		newTracks, err := db.GetTracks(ctx, newIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load merged tracks: %w", err)
+		}
+
+		for id, track := range pending {
+			newTrack, exists := newTracks[track.NewID]
+			if !exists || visited[id][newTrack.ID] {
+				if exists {
+					log.Printf("Track %d has cyclic merges, stopping at track %d", id, track.ID)
+				}
+				resolved[id] = track
+				delete(pending, id)
+				continue
+			}
+			visited[id][newTrack.ID] = true
+			if newTrack.NewID == 0 {
+				resolved[id] = newTrack
+				delete(pending, id)
+				continue
+			}
+			pending[id] = newTrack
+		}
+	}
+	return resolved, nil
+}
+
+func convertLinkedTracks(rows map[string][]fingerprint_db.LinkedTrack) map[string][]services.LinkedTrack {
+	tracks := make(map[string][]services.LinkedTrack, len(rows))
+	for key, keyRows := range rows {
+		keyTracks := make([]services.LinkedTrack, len(keyRows))
+		for i, row := range keyRows {
+			keyTracks[i] = services.LinkedTrack{GID: row.TrackGID, Disabled: row.Disabled}
+		}
+		tracks[key] = keyTracks
+	}
+	return tracks
+}
+
+func (s *TrackService) GetTracksByMBIDs(ctx context.Context, mbids []string, includeDisabled bool) (map[string][]services.LinkedTrack, error) {
+	rows, err := s.FingerprintDB.GetTracksByMBIDs(ctx, mbids, includeDisabled)
+	if err != nil {
+		return nil, err
+	}
+	return convertLinkedTracks(rows), nil
+}
+
+func (s *TrackService) GetTracksByPUIDs(ctx context.Context, puids []string) (map[string][]services.LinkedTrack, error) {
+	rows, err := s.FingerprintDB.GetTracksByPUIDs(ctx, puids)
+	if err != nil {
+		return nil, err
+	}
+	return convertLinkedTracks(rows), nil
+}
+
+func (s *TrackService) GetTracksByForeignIDs(ctx context.Context, foreignIDs []services.ForeignID) (map[string][]services.LinkedTrack, error) {
+	keys := make([]fingerprint_db.ForeignID, len(foreignIDs))
+	for i, foreignID := range foreignIDs {
+		keys[i] = fingerprint_db.ForeignID{Vendor: foreignID.Vendor, Name: foreignID.Name}
+	}
+	rows, err := s.FingerprintDB.GetTracksByForeignIDs(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return convertLinkedTracks(rows), nil
+}
@@ -0,0 +1,89 @@
+package legacy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/acoustid/go-acoustid/database/fingerprint_db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockTrackLoader struct {
+	tracks map[int]fingerprint_db.Track
+	calls  int
+}
+
+func newMockTrackLoader(tracks ...fingerprint_db.Track) *mockTrackLoader {
+	loader := &mockTrackLoader{tracks: make(map[int]fingerprint_db.Track)}
+	for _, track := range tracks {
+		loader.tracks[track.ID] = track
+	}
+	return loader
+}
+
+func (l *mockTrackLoader) GetTracks(ctx context.Context, ids []int) (map[int]fingerprint_db.Track, error) {
+	l.calls++
+	tracks := make(map[int]fingerprint_db.Track)
+	for _, id := range ids {
+		if track, exists := l.tracks[id]; exists {
+			tracks[id] = track
+		}
+	}
+	return tracks, nil
+}
+
+func TestResolveMergedTracks_Chain(t *testing.T) {
+	ctx := context.Background()
+	loader := newMockTrackLoader(
+		fingerprint_db.Track{ID: 1, GID: "a", NewID: 2},
+		fingerprint_db.Track{ID: 2, GID: "b", NewID: 3},
+		fingerprint_db.Track{ID: 3, GID: "c"},
+		fingerprint_db.Track{ID: 4, GID: "d"},
+	)
+
+	resolved, err := resolveMergedTracks(ctx, loader, []fingerprint_db.Track{loader.tracks[1], loader.tracks[2], loader.tracks[4]})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]fingerprint_db.Track{
+		1: loader.tracks[3],
+		2: loader.tracks[3],
+		4: loader.tracks[4],
+	}, resolved)
+	// one query per merge level, not per track
+	assert.Equal(t, 2, loader.calls)
+}
+
+func TestResolveMergedTracks_Cycle(t *testing.T) {
+	ctx := context.Background()
+	loader := newMockTrackLoader(
+		fingerprint_db.Track{ID: 1, GID: "a", NewID: 2},
+		fingerprint_db.Track{ID: 2, GID: "b", NewID: 3},
+		fingerprint_db.Track{ID: 3, GID: "c", NewID: 1},
+	)
+
+	resolved, err := resolveMergedTracks(ctx, loader, []fingerprint_db.Track{loader.tracks[1]})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]fingerprint_db.Track{1: loader.tracks[3]}, resolved)
+}
+
+func TestResolveMergedTracks_MissingTrack(t *testing.T) {
+	ctx := context.Background()
+	loader := newMockTrackLoader(fingerprint_db.Track{ID: 1, GID: "a", NewID: 2})
+
+	resolved, err := resolveMergedTracks(ctx, loader, []fingerprint_db.Track{loader.tracks[1]})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]fingerprint_db.Track{1: loader.tracks[1]}, resolved)
+}
+
+func TestResolveMergedTracks_MaxDepth(t *testing.T) {
+	ctx := context.Background()
+	loader := newMockTrackLoader()
+	for id := 1; id <= maxMergeDepth+5; id++ {
+		loader.tracks[id] = fingerprint_db.Track{ID: id, NewID: id + 1}
+	}
+
+	resolved, err := resolveMergedTracks(ctx, loader, []fingerprint_db.Track{loader.tracks[1]})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]fingerprint_db.Track{1: loader.tracks[maxMergeDepth+1]}, resolved)
+	assert.Equal(t, maxMergeDepth, loader.calls)
+}
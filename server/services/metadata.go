@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+type Artist struct {
+	ID         string
+	Name       string
+	JoinPhrase string
+}
+
+type Track struct {
+	ID       string
+	Title    string
+	Position int
+	Artists  []Artist
+}
+
+type Medium struct {
+	Position   int
+	Format     string
+	TrackCount int
+	Tracks     []Track
+}
+
+type ReleaseGroup struct {
+	ID      string
+	Title   string
+	Type    string
+	Artists []Artist
+}
+
+type Release struct {
+	ID           string
+	Title        string
+	Artists      []Artist
+	MediumCount  int
+	Mediums      []Medium
+	ReleaseGroup ReleaseGroup
+}
+
+type Recording struct {
+	ID       string
+	Title    string
+	Duration time.Duration
+	Artists  []Artist
+	Releases []Release
+}
+
+// MetadataOptions controls how much MusicBrainz metadata is loaded for each recording.
+// If neither option is set, only recording IDs are returned.
+type MetadataOptions struct {
+	Recordings bool
+	Releases   bool
+}
+
+type MetadataService interface {
+	// GetTrackRecordings returns MusicBrainz recordings linked to the given AcoustID tracks, keyed by track ID.
+	GetTrackRecordings(ctx context.Context, trackIDs []int, opts MetadataOptions) (map[int][]Recording, error)
+	// GetTrackForeignIDs returns foreign IDs linked to the given AcoustID tracks, keyed by track ID.
+	GetTrackForeignIDs(ctx context.Context, trackIDs []int) (map[int][]ForeignID, error)
+}
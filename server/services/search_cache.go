@@ -0,0 +1,220 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/acoustid/go-acoustid/chromaprint"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	searchCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "acoustid_api_search_cache_hits_total",
+		Help: "Number of fingerprint searches answered from the cache",
+	})
+	searchCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "acoustid_api_search_cache_misses_total",
+		Help: "Number of fingerprint searches not found in the cache",
+	})
+	searchCacheCoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "acoustid_api_search_cache_coalesced_total",
+		Help: "Number of fingerprint searches that waited for an identical search already in progress",
+	})
+)
+
+// DefaultSearchCacheDurationBucket is the default granularity of audio durations in search cache keys.
+const DefaultSearchCacheDurationBucket = time.Second
+
+var errSearchPanicked = errors.New("fingerprint search panicked")
+
+type searchCacheKey [sha256.Size]byte
+
+type searchCacheEntry struct {
+	key     searchCacheKey
+	results []FingerprintSearchResult
+	expires time.Time
+}
+
+type searchCall struct {
+	done    chan struct{}
+	results []FingerprintSearchResult
+	err     error
+}
+
+// CachedFingerprintSearcher keeps results of recent searches in memory for a limited time,
+// so that repeated lookups of popular files do not need to query the index and the database.
+// Identical searches running at the same time are coalesced into one. Searches are identified
+// by the fingerprint, the audio duration rounded to DurationBucket and the search options,
+// so requests with durations in the same bucket share results.
+type CachedFingerprintSearcher struct {
+	Searcher       FingerprintSearcher
+	TTL            time.Duration
+	MaxSize        int
+	DurationBucket time.Duration
+
+	mu      sync.Mutex
+	entries map[searchCacheKey]*list.Element
+	lru     *list.List
+	calls   map[searchCacheKey]*searchCall
+	now     func() time.Time
+}
+
+func NewCachedFingerprintSearcher(searcher FingerprintSearcher, ttl time.Duration, maxSize int) *CachedFingerprintSearcher {
+	return &CachedFingerprintSearcher{
+		Searcher:       searcher,
+		TTL:            ttl,
+		MaxSize:        maxSize,
+		DurationBucket: DefaultSearchCacheDurationBucket,
+		entries:        make(map[searchCacheKey]*list.Element),
+		lru:            list.New(),
+		calls:          make(map[searchCacheKey]*searchCall),
+		now:            time.Now,
+	}
+}
+
+func (s *CachedFingerprintSearcher) Search(ctx context.Context, fingerprint chromaprint.Fingerprint, duration time.Duration, opts SearchOptions) ([]FingerprintSearchResult, error) {
+	key := s.key(fingerprint, duration, opts)
+
+	for {
+		s.mu.Lock()
+		if results, ok := s.get(key); ok {
+			s.mu.Unlock()
+			searchCacheHits.Inc()
+			return copySearchResults(results), nil
+		}
+		if call, exists := s.calls[key]; exists {
+			s.mu.Unlock()
+			searchCacheCoalesced.Inc()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if call.err != nil && isContextError(call.err) && ctx.Err() == nil {
+				// The search was cancelled by its original caller, this one is still active, so try again.
+				continue
+			}
+			if call.err != nil {
+				return nil, call.err
+			}
+			return copySearchResults(call.results), nil
+		}
+		call := &searchCall{done: make(chan struct{})}
+		s.calls[key] = call
+		s.mu.Unlock()
+
+		searchCacheMisses.Inc()
+		s.search(ctx, key, call, fingerprint, duration, opts)
+		if call.err != nil {
+			return nil, call.err
+		}
+		return copySearchResults(call.results), nil
+	}
+}
+
+// search runs the search for all callers waiting on call. If it panics, the waiting callers get an error
+// and the panic continues in the current goroutine.
+func (s *CachedFingerprintSearcher) search(ctx context.Context, key searchCacheKey, call *searchCall, fingerprint chromaprint.Fingerprint, duration time.Duration, opts SearchOptions) {
+	panicked := true
+	defer func() {
+		if panicked {
+			call.results, call.err = nil, errSearchPanicked
+		}
+		s.mu.Lock()
+		delete(s.calls, key)
+		if call.err == nil {
+			s.add(key, call.results)
+		}
+		s.mu.Unlock()
+		close(call.done)
+	}()
+	call.results, call.err = s.Searcher.Search(ctx, fingerprint, duration, opts)
+	panicked = false
+}
+
+// Len returns the number of cached searches, including expired ones which were not evicted yet.
+func (s *CachedFingerprintSearcher) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+func (s *CachedFingerprintSearcher) get(key searchCacheKey) ([]FingerprintSearchResult, bool) {
+	elem, exists := s.entries[key]
+	if !exists {
+		return nil, false
+	}
+	entry := elem.Value.(*searchCacheEntry)
+	if !s.now().Before(entry.expires) {
+		s.lru.Remove(elem)
+		delete(s.entries, key)
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	return entry.results, true
+}
+
+func (s *CachedFingerprintSearcher) add(key searchCacheKey, results []FingerprintSearchResult) {
+	if s.MaxSize <= 0 || s.TTL <= 0 {
+		return
+	}
+	entry := &searchCacheEntry{key: key, results: results, expires: s.now().Add(s.TTL)}
+	if elem, exists := s.entries[key]; exists {
+		elem.Value = entry
+		s.lru.MoveToFront(elem)
+		return
+	}
+	for s.lru.Len() >= s.MaxSize {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*searchCacheEntry).key)
+	}
+	s.entries[key] = s.lru.PushFront(entry)
+}
+
+func (s *CachedFingerprintSearcher) key(fingerprint chromaprint.Fingerprint, duration time.Duration, opts SearchOptions) searchCacheKey {
+	if s.DurationBucket > 0 {
+		duration = duration.Round(s.DurationBucket)
+	}
+	h := sha256.New()
+	buf := make([]byte, 8)
+	writeUint64 := func(x uint64) {
+		binary.LittleEndian.PutUint64(buf, x)
+		h.Write(buf)
+	}
+	writeUint64(uint64(fingerprint.Version))
+	writeUint64(uint64(duration))
+	writeUint64(uint64(opts.MaxDurationDiff))
+	writeUint64(uint64(opts.MaxResults))
+	writeUint64(math.Float64bits(opts.MinScore))
+	writeUint64(uint64(opts.MaxCandidates))
+	writeUint64(uint64(opts.MinHits))
+	writeUint64(uint64(opts.MinHitsPercent))
+	writeUint64(uint64(len(fingerprint.Hashes)))
+	for _, hash := range fingerprint.Hashes {
+		binary.LittleEndian.PutUint32(buf, hash)
+		h.Write(buf[:4])
+	}
+	var key searchCacheKey
+	h.Sum(key[:0])
+	return key
+}
+
+func copySearchResults(results []FingerprintSearchResult) []FingerprintSearchResult {
+	if results == nil {
+		return nil
+	}
+	return append([]FingerprintSearchResult(nil), results...)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/acoustid/go-acoustid/chromaprint"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingFingerprintSearcher struct {
+	calls   int32
+	block   chan struct{}
+	started chan struct{}
+	err     error
+}
+
+func (s *countingFingerprintSearcher) Search(ctx context.Context, fingerprint chromaprint.Fingerprint, duration time.Duration, opts SearchOptions) ([]FingerprintSearchResult, error) {
+	calls := atomic.AddInt32(&s.calls, 1)
+	if s.started != nil {
+		s.started <- struct{}{}
+	}
+	if s.block != nil {
+		select {
+		case <-s.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []FingerprintSearchResult{{TrackID: int(calls), TrackGID: "a", Score: 1.0}}, nil
+}
+
+func TestCachedFingerprintSearcher(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingFingerprintSearcher{}
+	searcher := NewCachedFingerprintSearcher(inner, time.Minute, 2)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	searcher.now = func() time.Time { return now }
+
+	fp1 := chromaprint.Fingerprint{Version: 1, Hashes: []uint32{1, 2, 3}}
+	fp2 := chromaprint.Fingerprint{Version: 1, Hashes: []uint32{1, 2, 4}}
+	opts := NewSearchOptions()
+
+	results, err := searcher.Search(ctx, fp1, 100*time.Second, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, results[0].TrackID)
+
+	results[0].TrackID = 100
+	results, err = searcher.Search(ctx, fp1, 100*time.Second+300*time.Millisecond, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, results[0].TrackID, "cached results should not be modified by callers")
+	assert.Equal(t, int32(1), inner.calls)
+
+	results, err = searcher.Search(ctx, fp1, 101*time.Second, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, results[0].TrackID)
+
+	opts2 := opts
+	opts2.MaxDurationDiff = 10 * time.Second
+	results, err = searcher.Search(ctx, fp1, 100*time.Second, opts2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, results[0].TrackID)
+	assert.Equal(t, 2, searcher.Len())
+
+	results, err = searcher.Search(ctx, fp2, 100*time.Second, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 4, results[0].TrackID)
+	assert.Equal(t, 2, searcher.Len())
+
+	now = now.Add(time.Minute)
+	results, err = searcher.Search(ctx, fp2, 100*time.Second, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 5, results[0].TrackID)
+}
+
+func TestCachedFingerprintSearcher_Error(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingFingerprintSearcher{err: context.DeadlineExceeded}
+	searcher := NewCachedFingerprintSearcher(inner, time.Minute, 10)
+	fp := chromaprint.Fingerprint{Version: 1, Hashes: []uint32{1, 2, 3}}
+
+	_, err := searcher.Search(ctx, fp, 100*time.Second, NewSearchOptions())
+	assert.Error(t, err)
+	_, err = searcher.Search(ctx, fp, 100*time.Second, NewSearchOptions())
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), inner.calls)
+	assert.Equal(t, 0, searcher.Len())
+}
+
+func TestCachedFingerprintSearcher_Coalesce(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingFingerprintSearcher{block: make(chan struct{}), started: make(chan struct{}, 10)}
+	searcher := NewCachedFingerprintSearcher(inner, time.Minute, 10)
+	fp := chromaprint.Fingerprint{Version: 1, Hashes: []uint32{1, 2, 3}}
+
+	var wg sync.WaitGroup
+	results := make([][]FingerprintSearchResult, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := searcher.Search(ctx, fp, 100*time.Second, NewSearchOptions())
+			assert.NoError(t, err)
+			results[i] = res
+		}(i)
+	}
+	<-inner.started
+	for {
+		searcher.mu.Lock()
+		n := len(searcher.calls)
+		searcher.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(inner.block)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inner.calls))
+	for _, res := range results {
+		assert.Equal(t, []FingerprintSearchResult{{TrackID: 1, TrackGID: "a", Score: 1.0}}, res)
+	}
+}
+
+func TestCachedFingerprintSearcher_CancelledLeader(t *testing.T) {
+	inner := &countingFingerprintSearcher{block: make(chan struct{}), started: make(chan struct{}, 10)}
+	searcher := NewCachedFingerprintSearcher(inner, time.Minute, 10)
+	fp := chromaprint.Fingerprint{Version: 1, Hashes: []uint32{1, 2, 3}}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() {
+		_, err := searcher.Search(leaderCtx, fp, 100*time.Second, NewSearchOptions())
+		leaderDone <- err
+	}()
+	<-inner.started
+
+	followerDone := make(chan []FingerprintSearchResult, 1)
+	go func() {
+		res, err := searcher.Search(context.Background(), fp, 100*time.Second, NewSearchOptions())
+		assert.NoError(t, err)
+		followerDone <- res
+	}()
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-leaderDone)
+	<-inner.started
+	close(inner.block)
+	res := <-followerDone
+	assert.Equal(t, 2, res[0].TrackID)
+}
+
+type panickingFingerprintSearcher struct {
+	block chan struct{}
+}
+
+func (s *panickingFingerprintSearcher) Search(ctx context.Context, fingerprint chromaprint.Fingerprint, duration time.Duration, opts SearchOptions) ([]FingerprintSearchResult, error) {
+	<-s.block
+	panic("boom")
+}
+
+func TestCachedFingerprintSearcher_PanickedLeader(t *testing.T) {
+	inner := &panickingFingerprintSearcher{block: make(chan struct{})}
+	searcher := NewCachedFingerprintSearcher(inner, time.Minute, 10)
+	fp := chromaprint.Fingerprint{Version: 1, Hashes: []uint32{1, 2, 3}}
+
+	leaderDone := make(chan interface{}, 1)
+	go func() {
+		defer func() { leaderDone <- recover() }()
+		searcher.Search(context.Background(), fp, 100*time.Second, NewSearchOptions())
+	}()
+	require.Eventually(t, func() bool {
+		searcher.mu.Lock()
+		defer searcher.mu.Unlock()
+		return len(searcher.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	coalesced := testutil.ToFloat64(searchCacheCoalesced)
+	followerDone := make(chan error, 1)
+	go func() {
+		_, err := searcher.Search(context.Background(), fp, 100*time.Second, NewSearchOptions())
+		followerDone <- err
+	}()
+	require.Eventually(t, func() bool { return testutil.ToFloat64(searchCacheCoalesced) > coalesced }, time.Second, time.Millisecond)
+	close(inner.block)
+
+	assert.Equal(t, "boom", <-leaderDone)
+	assert.Equal(t, errSearchPanicked, <-followerDone)
+	assert.Empty(t, searcher.calls)
+	assert.Equal(t, 0, searcher.Len())
+}
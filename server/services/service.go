@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"github.com/acoustid/go-acoustid/chromaprint"
@@ -11,8 +12,64 @@ type FingerprintSearchResult struct {
 	TrackID  int
 	TrackGID string
 	Score    float64
+	// SubmissionCount is the total number of submissions of the matched fingerprints.
+	SubmissionCount int
+}
+
+// AggregateSearchResults merges results of the same track into one, keeping the best score
+// and summing the submission counts. Results are ordered by score, the order of equal scores is preserved.
+func AggregateSearchResults(results []FingerprintSearchResult) []FingerprintSearchResult {
+	aggregated := make([]FingerprintSearchResult, 0, len(results))
+	index := make(map[string]int, len(results))
+	for _, result := range results {
+		i, exists := index[result.TrackGID]
+		if !exists {
+			index[result.TrackGID] = len(aggregated)
+			aggregated = append(aggregated, result)
+			continue
+		}
+		merged := &aggregated[i]
+		if result.Score > merged.Score {
+			merged.Score = result.Score
+		}
+		merged.SubmissionCount += result.SubmissionCount
+	}
+	sort.SliceStable(aggregated, func(i, j int) bool { return aggregated[i].Score > aggregated[j].Score })
+	return aggregated
+}
+
+// DefaultMaxDurationDiff is the default tolerance for differences between the duration
+// of the searched audio and durations of the stored fingerprints.
+const DefaultMaxDurationDiff = 7 * time.Second
+
+type SearchOptions struct {
+	// MaxDurationDiff is the maximum difference between the searched and stored audio duration.
+	MaxDurationDiff time.Duration
+	// MaxResults limits the number of returned results, 0 means no limit.
+	MaxResults int
+	// MinScore is the minimum score of returned results.
+	MinScore float64
+	// MaxCandidates is the maximum number of index results, those with the most matching hashes,
+	// that are compared with the searched fingerprint. Each candidate is loaded and compared in full,
+	// so this bounds the cost of a search. The default of 11 is the number of candidates the legacy
+	// API server compares, so that both servers return the same results for the same request.
+	MaxCandidates int
+	// MinHits is the minimum number of matching hashes for the best candidate to be considered at all.
+	MinHits int
+	// MinHitsPercent is the minimum number of matching hashes of other candidates,
+	// relative to the best candidate.
+	MinHitsPercent int
+}
+
+func NewSearchOptions() SearchOptions {
+	return SearchOptions{
+		MaxDurationDiff: DefaultMaxDurationDiff,
+		MaxCandidates:   11,
+		MinHits:         2,
+		MinHitsPercent:  50,
+	}
 }
 
 type FingerprintSearcher interface {
-	Search(ctx context.Context, fingerprint chromaprint.Fingerprint, duration time.Duration) ([]FingerprintSearchResult, error)
+	Search(ctx context.Context, fingerprint chromaprint.Fingerprint, duration time.Duration, opts SearchOptions) ([]FingerprintSearchResult, error)
 }
@@ -0,0 +1,23 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateSearchResults(t *testing.T) {
+	results := []FingerprintSearchResult{
+		{TrackID: 1, TrackGID: "a", Score: 0.7, SubmissionCount: 3},
+		{TrackID: 2, TrackGID: "b", Score: 0.8, SubmissionCount: 1},
+		{TrackID: 1, TrackGID: "a", Score: 0.9, SubmissionCount: 5},
+		{TrackID: 3, TrackGID: "c", Score: 0.8, SubmissionCount: 2},
+	}
+	assert.Equal(t, []FingerprintSearchResult{
+		{TrackID: 1, TrackGID: "a", Score: 0.9, SubmissionCount: 8},
+		{TrackID: 2, TrackGID: "b", Score: 0.8, SubmissionCount: 1},
+		{TrackID: 3, TrackGID: "c", Score: 0.8, SubmissionCount: 2},
+	}, AggregateSearchResults(results))
+
+	assert.Empty(t, AggregateSearchResults(nil))
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// LookupStats contains the number of lookups made by one application in one hour.
+type LookupStats struct {
+	ApplicationID int
+	Hour          time.Time
+	Hits          int
+	NoHits        int
+}
+
+type StatsService interface {
+	// UpdateLookupStats adds the counts to the stored lookup statistics.
+	UpdateLookupStats(ctx context.Context, stats []LookupStats) error
+}
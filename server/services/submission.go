@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/acoustid/go-acoustid/chromaprint"
+)
+
+type Account struct {
+	ID   int
+	Name string
+}
+
+type AccountService interface {
+	// GetAccountByAPIKey returns the account with the given user API key, or nil if the key is not known.
+	GetAccountByAPIKey(ctx context.Context, apiKey string) (*Account, error)
+}
+
+// ForeignID identifies a track in an external catalog, e.g. a music streaming service.
+type ForeignID struct {
+	Vendor string
+	Name   string
+}
+
+func (id ForeignID) String() string {
+	return id.Vendor + ":" + id.Name
+}
+
+type SubmissionMeta struct {
+	Track       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	TrackNo     int
+	DiscNo      int
+	Year        int
+}
+
+type Submission struct {
+	Fingerprint chromaprint.Fingerprint
+	Duration    time.Duration
+	Bitrate     int
+	Format      string
+	MBID        string
+	PUID        string
+	// Meta is shared by all submissions created from one submitted item and is only stored once for them.
+	Meta      *SubmissionMeta
+	ForeignID *ForeignID
+}
+
+// SubmissionStatus describes the processing state of a submission. TrackGID is set
+// once the submission was imported into the fingerprint database.
+type SubmissionStatus struct {
+	ID       int
+	Handled  bool
+	TrackGID string
+}
+
+type SubmissionService interface {
+	// Submit stores the submissions for later processing and returns their IDs.
+	Submit(ctx context.Context, app *Application, account *Account, clientVersion string, submissions []Submission) ([]int, error)
+	// GetSubmissionStatuses returns the statuses of the given submissions. Unknown submissions are skipped.
+	GetSubmissionStatuses(ctx context.Context, ids []int) ([]SubmissionStatus, error)
+}
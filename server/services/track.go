@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+)
+
+// TrackRef identifies an AcoustID track by its internal ID and its public AcoustID.
+type TrackRef struct {
+	ID  int
+	GID string
+}
+
+// LinkedTrack is a track linked to an external identifier, e.g. a MusicBrainz recording ID.
+// Links can be disabled by moderators if they are known to be incorrect.
+type LinkedTrack struct {
+	GID      string
+	Disabled bool
+}
+
+type TrackService interface {
+	// GetTrackByGID returns the track with the given AcoustID, or nil if it doesn't exist.
+	// If the track was merged into another one, the track it was merged into is returned.
+	GetTrackByGID(ctx context.Context, gid string) (*TrackRef, error)
+	// GetTracksByMBIDs returns tracks linked to the given MusicBrainz recording IDs, keyed by MBID.
+	GetTracksByMBIDs(ctx context.Context, mbids []string, includeDisabled bool) (map[string][]LinkedTrack, error)
+	// GetTracksByPUIDs returns tracks linked to the given MusicDNS PUIDs, keyed by PUID.
+	GetTracksByPUIDs(ctx context.Context, puids []string) (map[string][]LinkedTrack, error)
+	// GetTracksByForeignIDs returns tracks linked to the given foreign IDs, keyed by ForeignID.String().
+	GetTracksByForeignIDs(ctx context.Context, foreignIDs []ForeignID) (map[string][]LinkedTrack, error)
+}
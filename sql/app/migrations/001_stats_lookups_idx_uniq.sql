@@ -0,0 +1,27 @@
+-- Makes (date, hour, application_id) unique in stats_lookups, which the API server's
+-- INSERT ... ON CONFLICT relies on. Must be applied before deploying the API server.
+-- Rows duplicated by concurrent inserts are first merged into the one with the lowest id.
+
+BEGIN;
+
+LOCK TABLE public.stats_lookups IN SHARE ROW EXCLUSIVE MODE;
+
+CREATE TEMPORARY TABLE stats_lookups_duplicates ON COMMIT DROP AS
+SELECT min(id) AS keep_id, date, hour, application_id,
+       sum(count_hits) AS count_hits, sum(count_nohits) AS count_nohits
+FROM public.stats_lookups
+GROUP BY date, hour, application_id
+HAVING count(*) > 1;
+
+UPDATE public.stats_lookups s
+SET count_hits = d.count_hits, count_nohits = d.count_nohits
+FROM stats_lookups_duplicates d
+WHERE s.id = d.keep_id;
+
+DELETE FROM public.stats_lookups s
+USING stats_lookups_duplicates d
+WHERE s.date = d.date AND s.hour = d.hour AND s.application_id = d.application_id AND s.id <> d.keep_id;
+
+CREATE UNIQUE INDEX stats_lookups_idx_uniq ON public.stats_lookups USING btree (date, hour, application_id);
+
+COMMIT;
@@ -314,6 +314,10 @@ CREATE INDEX stats_lookups_idx_date ON public.stats_lookups USING btree (date);
 
 
 
+CREATE UNIQUE INDEX stats_lookups_idx_uniq ON public.stats_lookups USING btree (date, hour, application_id);
+
+
+
 CREATE INDEX stats_user_agents_idx_date ON public.stats_user_agents USING btree (date);
 
 
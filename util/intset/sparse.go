@@ -105,6 +105,19 @@ func (s *SparseBitSet) Union(s2 *SparseBitSet) {
 	}
 }
 
+// Difference updates the set to exclude all elements from s2.
+func (s *SparseBitSet) Difference(s2 *SparseBitSet) {
+	for i, block2 := range s2.blocks {
+		block, exists := s.blocks[i]
+		if !exists {
+			continue
+		}
+		for j, mask := range block2 {
+			block[j] &^= mask
+		}
+	}
+}
+
 func (s *SparseBitSet) Intersection(s2 *SparseBitSet) (*SparseBitSet, int) {
 	s3 := NewSparseBitSet(0)
 	n := 0
@@ -51,6 +51,25 @@ func TestSparseBitSet_Union(t *testing.T) {
 	require.True(t, s1.Contains(1001))
 }
 
+func TestSparseBitSet_Difference(t *testing.T) {
+	s1 := NewSparseBitSet(0)
+	s1.Add(1)
+	s1.Add(2)
+	s1.Add(100000)
+	s2 := NewSparseBitSet(0)
+	s2.Add(2)
+	s2.Add(3)
+	s2.Add(100000)
+	s2.Add(200000)
+	s1.Difference(s2)
+	require.True(t, s1.Contains(1))
+	require.False(t, s1.Contains(2))
+	require.False(t, s1.Contains(3))
+	require.False(t, s1.Contains(100000))
+	require.False(t, s1.Contains(200000))
+	require.Equal(t, 1, s1.Len())
+}
+
 func TestSparseBitSet_ReadWrite(t *testing.T) {
 	s := NewSparseBitSet(0)
 	data := make([]uint32, 1024)
@@ -100,12 +100,12 @@ func (fs *osFS) OpenFile(name string) (InputFile, error) {
 	if err != nil {
 		return nil, err
 	}
-	size, err := file.Seek(io.SeekEnd, 0)
+	size, err := file.Seek(0, io.SeekEnd)
 	if err != nil {
 		file.Close()
 		return nil, err
 	}
-	_, err = file.Seek(io.SeekStart, 0)
+	_, err = file.Seek(0, io.SeekStart)
 	if err != nil {
 		file.Close()
 		return nil, err
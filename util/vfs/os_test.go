@@ -4,6 +4,7 @@
 package vfs
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -46,3 +47,24 @@ func TestCreateTempDir(t *testing.T) {
 
 	assert.True(t, strings.HasPrefix(fs.Path(), os.TempDir()))
 }
+
+func TestOpenFile_Size(t *testing.T) {
+	fs, err := CreateTempDir()
+	require.NoError(t, err)
+	defer fs.Close()
+
+	err = WriteFile(fs, "foo", func(w io.Writer) error {
+		_, err := w.Write([]byte("hello world"))
+		return err
+	})
+	require.NoError(t, err)
+
+	file, err := fs.OpenFile("foo")
+	require.NoError(t, err)
+	defer file.Close()
+	assert.Equal(t, int64(11), file.Size())
+
+	data, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}